@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	_MaxTweetLength = 280
+	_URLLength      = 23
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s]+`)
+
+// weightedLength returns length of the text counted as twitter does. URLs are
+// counted as 23 characters, and wide characters (ex: CJK, emoji) are counted
+// as 2 characters.
+func weightedLength(text string) int {
+	n := len(urlPattern.FindAllString(text, -1)) * _URLLength
+	for _, r := range urlPattern.ReplaceAllString(text, "") {
+		switch {
+		case r <= 0x10FF,
+			0x2000 <= r && r <= 0x200D,
+			0x2010 <= r && r <= 0x201F,
+			0x2032 <= r && r <= 0x2037:
+			n++
+		default:
+			n += 2
+		}
+	}
+	return n
+}
+
+// withContentWarning prepends the content warning to the text
+func withContentWarning(cw string, text string) string {
+	if cw == "" {
+		return text
+	}
+	return "CW: " + cw + "\n\n" + text
+}
+
+// trimText removes a trailing newline of the text read from file. If all is
+// true, it removes all spaces and newlines around the text.
+func trimText(text string, all bool) string {
+	if all {
+		return strings.TrimSpace(text)
+	}
+	if strings.HasSuffix(text, "\r\n") {
+		return text[:len(text)-2]
+	}
+	return strings.TrimSuffix(text, "\n")
+}
+
+// splitThread splits the text longer than limit into tweets of a thread on
+// sentence or word boundaries, appending counters like " (1/3)"
+func splitThread(text string, limit int) []string {
+	text = strings.TrimSpace(text)
+	if weightedLength(text) <= limit {
+		return []string{text}
+	}
+	for total := 9; ; total = total*10 + 9 {
+		// reserve room for the counter of the widest number
+		parts := splitText(text, limit-weightedLength(fmt.Sprintf(" (%d/%d)", total, total)))
+		if len(parts) <= total {
+			for i := range parts {
+				parts[i] += fmt.Sprintf(" (%d/%d)", i+1, len(parts))
+			}
+			return parts
+		}
+	}
+}
+
+// splitText splits the text into parts shorter than max. It packs sentences
+// into a part, and splits a sentence into words, or a word into characters
+// only if it does not fit.
+func splitText(text string, max int) []string {
+	var parts []string
+	var part string
+	add := func(token string, sep string) {
+		if part != "" && weightedLength(part+sep+token) <= max {
+			part += sep + token
+			return
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+		part = token
+	}
+	for _, sentence := range sentences(text) {
+		if weightedLength(sentence) <= max {
+			add(sentence, " ")
+			continue
+		}
+		for _, word := range strings.Fields(sentence) {
+			for weightedLength(word) > max {
+				// cut the word which is longer than max
+				runes := []rune(word)
+				n := len(runes)
+				for n > 1 && weightedLength(string(runes[:n])) > max {
+					n--
+				}
+				add(string(runes[:n]), " ")
+				word = string(runes[n:])
+			}
+			add(word, " ")
+		}
+	}
+	if part != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// sentences splits the text after sentence terminators and newlines
+func sentences(text string) []string {
+	var result []string
+	runes := []rune(text)
+	start := 0
+	for i, r := range runes {
+		end := false
+		switch r {
+		case '\n', '。', '！', '？':
+			end = true
+		case '.', '!', '?':
+			end = i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+		}
+		if end {
+			if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+				result = append(result, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		result = append(result, s)
+	}
+	return result
+}
+
+// stripComments removes lines beginning with '#' and surrounding spaces
+func stripComments(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, "\r"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// editText opens the editor with the text, and returns the edited text
+func editText(text string) (string, error) {
+	f, err := ioutil.TempFile("", "twty-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(text)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return "", fmt.Errorf("cannot run editor: %v", err)
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// composeTemplate returns the initial text for the editor
+func composeTemplate(signature string, inreply *Tweet) string {
+	var buf strings.Builder
+	buf.WriteString("\n")
+	if signature != "" {
+		buf.WriteString(signature + "\n")
+	}
+	buf.WriteString("# Write your tweet. Lines starting with '#' will be ignored,\n")
+	buf.WriteString("# and an empty tweet aborts the post.\n")
+	if inreply != nil {
+		buf.WriteString("#\n")
+		buf.WriteString("# Replying to @" + inreply.User.ScreenName + ":\n")
+		for _, line := range strings.Split(inreply.Text, "\n") {
+			buf.WriteString("# > " + line + "\n")
+		}
+	}
+	return buf.String()
+}
+
+// composeInteractive reads lines of a tweet from r, showing the weighted
+// length and characters left after each line. A line of "." submits the
+// tweet unless it is too long, "/undo" removes the last line and "/clear"
+// removes all lines. decorate returns the text actually posted, like with
+// content warning, which is counted instead of the input.
+func composeInteractive(r io.Reader, w io.Writer, decorate func(string) string) (string, error) {
+	fmt.Fprintln(w, `Write your tweet. "." submits, "/undo" removes the last line, "/clear" starts over.`)
+	var lines []string
+	stdin := bufio.NewScanner(r)
+	for {
+		text := strings.Join(lines, "\n")
+		n := weightedLength(decorate(text))
+		if n > _MaxTweetLength {
+			fmt.Fprintf(w, "[%d/%d, over by %d] ", n, _MaxTweetLength, n-_MaxTweetLength)
+		} else {
+			fmt.Fprintf(w, "[%d/%d, %d left] ", n, _MaxTweetLength, _MaxTweetLength-n)
+		}
+		if !stdin.Scan() {
+			if err := stdin.Err(); err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("canceled")
+		}
+		line := strings.TrimRight(stdin.Text(), "\r")
+		switch line {
+		case ".":
+			if strings.TrimSpace(text) == "" {
+				fmt.Fprintln(w, "tweet is empty")
+				continue
+			}
+			if n > _MaxTweetLength {
+				fmt.Fprintf(w, "tweet is too long, remove %d characters\n", n-_MaxTweetLength)
+				continue
+			}
+			return text, nil
+		case "/undo":
+			if len(lines) > 0 {
+				lines = lines[:len(lines)-1]
+			}
+		case "/clear":
+			lines = nil
+		default:
+			lines = append(lines, line)
+		}
+	}
+}
+
+// validateCoordinates checks that latitude and longitude of -lat and -long
+// are both specified and in range
+func validateCoordinates(lat, long string) error {
+	if lat == "" || long == "" {
+		return fmt.Errorf("both of latitude and longitude are required")
+	}
+	la, err := strconv.ParseFloat(lat, 64)
+	if err != nil || la < -90 || la > 90 {
+		return fmt.Errorf("latitude must be -90 to 90: %q", lat)
+	}
+	lo, err := strconv.ParseFloat(long, 64)
+	if err != nil || lo < -180 || lo > 180 {
+		return fmt.Errorf("longitude must be -180 to 180: %q", long)
+	}
+	return nil
+}
+
+var mentionPattern = regexp.MustCompile(`@([0-9A-Za-z_]{1,15})`)
+
+// replyMentions returns screen names to mention in the reply to the tweet,
+// the author first and users mentioned in the tweet, except me
+func replyMentions(tweet Tweet, me string) []string {
+	names := []string{tweet.User.ScreenName}
+	for _, mention := range tweet.Entities.UserMentions {
+		names = append(names, mention.ScreenName)
+	}
+	if len(tweet.Entities.UserMentions) == 0 {
+		// entities are not available with API v2
+		for _, m := range mentionPattern.FindAllStringSubmatch(tweet.Text, -1) {
+			names = append(names, m[1])
+		}
+	}
+	var mentions []string
+	seen := map[string]bool{strings.ToLower(me): true, "": true}
+	for _, name := range names {
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions
+}
+
+// prependMentions prepends @mentions to the text, except ones already in it
+func prependMentions(mentions []string, text string) string {
+	mentioned := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		mentioned[strings.ToLower(m[1])] = true
+	}
+	var prefix string
+	for _, name := range mentions {
+		if !mentioned[strings.ToLower(name)] {
+			prefix += "@" + name + " "
+		}
+	}
+	return prefix + text
+}
+
+// countdown shows seconds left of the delay on w, and returns false if it is
+// interrupted with Ctrl-C.
+func countdown(w io.Writer, delay time.Duration) bool {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	deadline := time.Now().Add(delay)
+	for {
+		left := time.Until(deadline)
+		if left <= 0 {
+			fmt.Fprintln(w)
+			return true
+		}
+		fmt.Fprintf(w, "\rposting in %d seconds... (Ctrl-C to cancel) ", int((left+time.Second-1)/time.Second))
+		wait := time.Second
+		if left < wait {
+			wait = left
+		}
+		select {
+		case <-sig:
+			fmt.Fprintln(w)
+			return false
+		case <-time.After(wait):
+		}
+	}
+}
+
+// confirm asks user yes or no, and returns true when answered yes
+func confirm(prompt string) bool {
+	fmt.Print(strings.Replace(prompts["PromptConfirm"], "{question}", prompt, -1))
+	stdin := bufio.NewScanner(os.Stdin)
+	if !stdin.Scan() {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(stdin.Text()))
+	return answer == "y" || answer == "yes"
+}
+
+// stopWords hold frequent words of languages written in Latin alphabet
+var stopWords = map[string][]string{
+	"en": {"the", "and", "is", "are", "you", "this", "that", "with", "for", "of"},
+	"es": {"el", "la", "los", "las", "que", "y", "es", "por", "con", "una"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "pour", "avec", "pas"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "mit", "ein", "zu"},
+	"pt": {"o", "os", "que", "e", "não", "uma", "com", "para", "por", "é"},
+	"it": {"il", "che", "e", "di", "non", "per", "una", "sono", "gli", "con"},
+}
+
+// detectLanguage guesses language of the text from its characters. It
+// returns "und" when the language cannot be guessed.
+func detectLanguage(text string) string {
+	counts := map[string]int{}
+	for _, r := range urlPattern.ReplaceAllString(text, "") {
+		switch {
+		case 0x3040 <= r && r <= 0x30FF:
+			counts["ja"]++
+		case 0xAC00 <= r && r <= 0xD7AF, 0x1100 <= r && r <= 0x11FF:
+			counts["ko"]++
+		case 0x4E00 <= r && r <= 0x9FFF:
+			counts["zh"]++
+		case 0x0400 <= r && r <= 0x04FF:
+			counts["ru"]++
+		case 0x0600 <= r && r <= 0x06FF:
+			counts["ar"]++
+		case 0x0E00 <= r && r <= 0x0E7F:
+			counts["th"]++
+		case 0x0590 <= r && r <= 0x05FF:
+			counts["he"]++
+		case 0x0370 <= r && r <= 0x03FF:
+			counts["el"]++
+		case 0x0900 <= r && r <= 0x097F:
+			counts["hi"]++
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', 0xC0 <= r && r <= 0x24F:
+			counts["latin"]++
+		}
+	}
+	// Kanji are used in Japanese too
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["zh"]
+		counts["zh"] = 0
+	}
+	lang, max := "und", 0
+	for k, v := range counts {
+		if v > max || (v == max && k < lang) {
+			lang, max = k, v
+		}
+	}
+	if lang != "latin" {
+		return lang
+	}
+
+	lang, max = "und", 0
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for k, stops := range stopWords {
+		n := 0
+		for _, word := range words {
+			for _, stop := range stops {
+				if word == stop {
+					n++
+				}
+			}
+		}
+		if n > max || (n == max && n > 0 && k < lang) {
+			lang, max = k, n
+		}
+	}
+	return lang
+}
+
+// detectLanguageCommand guesses language of the text with the external
+// command, which reads the text from stdin and writes the language.
+func detectLanguageCommand(command string, text string) (string, error) {
+	cmd := shellCommand(command)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stderr = os.Stderr
+	b, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot run %q: %v", command, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// shellCommand returns command which runs the command line with the shell
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/c", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([a-zA-Z0-9_-]+)\}`)
+
+// expandTemplate replaces placeholders like {date} in the template. {date},
+// {time}, {datetime} and {weekday} are built in, and others are replaced with
+// output of the command configured as "TemplateCommand.NAME".
+func expandTemplate(tmpl string, config map[string]string, now time.Time) (string, error) {
+	values := map[string]string{
+		"date":     now.Format("2006-01-02"),
+		"time":     now.Format("15:04"),
+		"datetime": now.Format("2006-01-02 15:04"),
+		"weekday":  now.Weekday().String(),
+	}
+	var unresolved []string
+	for _, m := range placeholderPattern.FindAllStringSubmatch(tmpl, -1) {
+		name := m[1]
+		if _, ok := values[name]; ok {
+			continue
+		}
+		command, ok := config["TemplateCommand."+name]
+		if !ok {
+			unresolved = append(unresolved, m[0])
+			continue
+		}
+		cmd := shellCommand(command)
+		cmd.Stderr = os.Stderr
+		b, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("cannot run %q for {%s}: %v", command, name, err)
+		}
+		values[name] = strings.TrimSpace(string(b))
+	}
+	if len(unresolved) > 0 {
+		return "", fmt.Errorf("unresolved placeholders: %s", strings.Join(unresolved, ", "))
+	}
+	return placeholderPattern.ReplaceAllStringFunc(tmpl, func(s string) string {
+		return values[s[1:len(s)-1]]
+	}), nil
+}
+
+// TweetRequest hold information about tweet to post given as JSON
+type TweetRequest struct {
+	Text    string   `json:"text"`
+	Media   []string `json:"media,omitempty"`
+	ReplyTo string   `json:"reply_to,omitempty"`
+}
+
+// readTweetRequests reads a JSON object or an array of JSON objects
+func readTweetRequests(r io.Reader) ([]TweetRequest, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var requests []TweetRequest
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(raw, &requests); err != nil {
+			return nil, err
+		}
+	} else {
+		var request TweetRequest
+		if err := json.Unmarshal(raw, &request); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	for i, request := range requests {
+		if err := request.validate(); err != nil {
+			return nil, fmt.Errorf("tweet #%d: %v", i+1, err)
+		}
+	}
+	return requests, nil
+}
+
+func (t *TweetRequest) validate() error {
+	if strings.TrimSpace(t.Text) == "" && len(t.Media) == 0 {
+		return fmt.Errorf("text or media is required")
+	}
+	if n := weightedLength(t.Text); n > _MaxTweetLength {
+		return fmt.Errorf("text is too long: %d/%d", n, _MaxTweetLength)
+	}
+	for _, file := range t.Media {
+		if isMediaURL(file) {
+			// media URLs are fetched when uploaded
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+	}
+	if t.ReplyTo != "" {
+		if _, err := strconv.ParseInt(t.ReplyTo, 10, 64); err != nil {
+			return fmt.Errorf("invalid reply_to: %q", t.ReplyTo)
+		}
+	}
+	return nil
+}
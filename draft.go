@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Draft hold information about a tweet saved to post later
+type Draft struct {
+	ID      int       `json:"id"`
+	Text    string    `json:"text"`
+	Media   []string  `json:"media,omitempty"`
+	ReplyTo string    `json:"reply_to,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// drafts hold drafts stored in the file next to the configuration file
+type drafts struct {
+	file   string
+	NextID int     `json:"next_id"`
+	Drafts []Draft `json:"drafts"`
+}
+
+func loadDrafts(file string) (*drafts, error) {
+	d := &drafts{file: file, NextID: 1}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return d, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, d); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %v: %v", file, err)
+	}
+	return d, nil
+}
+
+// add stores the draft with a new ID, and returns the ID
+func (d *drafts) add(draft Draft) int {
+	draft.ID = d.NextID
+	draft.Updated = time.Now()
+	d.NextID++
+	d.Drafts = append(d.Drafts, draft)
+	return draft.ID
+}
+
+// find returns the draft of the ID given as string
+func (d *drafts) find(id string) (*Draft, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid draft ID: %q", id)
+	}
+	for i := range d.Drafts {
+		if d.Drafts[i].ID == n {
+			return &d.Drafts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("draft %d not found", n)
+}
+
+func (d *drafts) remove(id int) {
+	for i := range d.Drafts {
+		if d.Drafts[i].ID == id {
+			d.Drafts = append(d.Drafts[:i], d.Drafts[i+1:]...)
+			return
+		}
+	}
+}
+
+func (d *drafts) save() error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(d.file, b, 0600)
+}
+
+// showDrafts prints the drafts with their IDs
+func showDrafts(drafts []Draft, asjson bool) {
+	if asjson {
+		json.NewEncoder(os.Stdout).Encode(drafts)
+		return
+	}
+	for _, draft := range drafts {
+		fmt.Printf("%d\t%s\t%s\n", draft.ID, draft.Updated.Local().Format("2006-01-02 15:04"), replacer.Replace(draft.Text))
+		if draft.ReplyTo != "" {
+			fmt.Printf("\treply to %s\n", draft.ReplyTo)
+		}
+		for _, file := range draft.Media {
+			fmt.Printf("\tmedia %s\n", file)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// subtractIDs returns IDs in a which are not in b, in the order of a
+func subtractIDs(a []int64, b []int64) []int64 {
+	inB := make(map[int64]bool)
+	for _, id := range b {
+		inB[id] = true
+	}
+	var ids []int64
+	for _, id := range a {
+		if !inB[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// intersectIDs returns IDs in both a and b, in the order of a
+func intersectIDs(a []int64, b []int64) []int64 {
+	inB := make(map[int64]bool)
+	for _, id := range b {
+		inB[id] = true
+	}
+	var ids []int64
+	for _, id := range a {
+		if inB[id] {
+			ids = append(ids, id)
+			// IDs may be duplicated across pages
+			delete(inB, id)
+		}
+	}
+	return ids
+}
+
+// _MaxSnapshots is the number of times of follower snapshots kept
+const _MaxSnapshots = 100
+
+// snapshot hold information about when a follower snapshot is taken
+type snapshot struct {
+	Taken     time.Time `json:"taken"`
+	Followers int       `json:"followers"`
+}
+
+// followerSnapshot hold IDs of followers at the last snapshot, and times of
+// recent snapshots, to find who unfollowed with -snapshot-followers
+type followerSnapshot struct {
+	file      string
+	Snapshots []snapshot `json:"snapshots"`
+	IDs       []int64    `json:"ids"`
+}
+
+func loadFollowerSnapshot(file string) (*followerSnapshot, error) {
+	s := &followerSnapshot{file: file}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %v: %v", file, err)
+	}
+	return s, nil
+}
+
+// last returns the last snapshot, or nil if no snapshot is taken yet
+func (s *followerSnapshot) last() *snapshot {
+	if len(s.Snapshots) == 0 {
+		return nil
+	}
+	return &s.Snapshots[len(s.Snapshots)-1]
+}
+
+// take replaces the followers with ids, and returns new followers and
+// unfollowers since the last snapshot
+func (s *followerSnapshot) take(ids []int64, now time.Time) (followed []int64, unfollowed []int64) {
+	if s.last() != nil {
+		followed, unfollowed = subtractIDs(ids, s.IDs), subtractIDs(s.IDs, ids)
+	}
+	s.IDs = ids
+	s.Snapshots = append(s.Snapshots, snapshot{Taken: now, Followers: len(ids)})
+	if len(s.Snapshots) > _MaxSnapshots {
+		s.Snapshots = s.Snapshots[len(s.Snapshots)-_MaxSnapshots:]
+	}
+	return followed, unfollowed
+}
+
+func (s *followerSnapshot) save() error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.file, b, 0600)
+}
+
+// showFollowerChanges prints new followers and unfollowers since the
+// previous snapshot. Users who cannot be looked up, like suspended ones,
+// are shown with their IDs.
+func showFollowerChanges(previous *snapshot, current snapshot, followed []User, unfollowed []User, missing []int64, asjson bool) {
+	if asjson {
+		json.NewEncoder(os.Stdout).Encode(struct {
+			Previous   *snapshot `json:"previous"`
+			Current    snapshot  `json:"current"`
+			Followed   []User    `json:"followed"`
+			Unfollowed []User    `json:"unfollowed"`
+			Missing    []int64   `json:"missing,omitempty"`
+		}{previous, current, followed, unfollowed, missing})
+		os.Stdout.Sync()
+		return
+	}
+	if previous == nil {
+		fmt.Printf("snapshot of %d followers taken at %s\n", current.Followers, current.Taken.Local().Format("2006-01-02 15:04"))
+		return
+	}
+	fmt.Printf("%d followers at %s (%d at %s)\n", current.Followers, current.Taken.Local().Format("2006-01-02 15:04"), previous.Followers, previous.Taken.Local().Format("2006-01-02 15:04"))
+	for _, user := range followed {
+		fmt.Printf("+ @%s (%s)\n", user.ScreenName, user.Name)
+	}
+	for _, user := range unfollowed {
+		fmt.Printf("- @%s (%s)\n", user.ScreenName, user.Name)
+	}
+	for _, id := range missing {
+		fmt.Printf("? %d (not found)\n", id)
+	}
+}
+
+// unfollowProgress hold users left to unfollow with -unfollow-nonmutual, so
+// that it can be resumed if it is interrupted
+type unfollowProgress struct {
+	file    string
+	Started time.Time `json:"started"`
+	Pending []int64   `json:"pending"`
+}
+
+func loadUnfollowProgress(file string) (*unfollowProgress, error) {
+	p := &unfollowProgress{file: file}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %v: %v", file, err)
+	}
+	return p, nil
+}
+
+// done removes the user from pending users
+func (p *unfollowProgress) done(id int64) {
+	for i := range p.Pending {
+		if p.Pending[i] == id {
+			p.Pending = append(p.Pending[:i], p.Pending[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *unfollowProgress) save() error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p.file, b, 0600)
+}
+
+// remove removes the progress file after all users are processed
+func (p *unfollowProgress) remove() error {
+	err := os.Remove(p.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// commandHelp hold detailed usage of each command shown by -help COMMAND
+var commandHelp = map[string]string{
+	"auth": `Authorization:
+  -a PROFILE: switch profile to load configuration file.
+  -a ?: list profiles.
+  -label: prefix tweets with the profile name given with -a, like
+      "[work] mattn_jp: hello".
+  -token TOKEN, -token-secret SECRET: use access token instead of
+      configuration file. TWTY_ACCESS_TOKEN and TWTY_ACCESS_SECRET are also
+      available.
+  -save-token: store access token given with -token into configuration file.
+  -reauthorize: authorize again with /oauth/authorize, which always shows
+      the approval screen, so that you can choose the account to authorize.
+  -api VERSION: version of API to use, "1.1" or "v2". "API" in
+      configuration file sets the default. timelines, search, tweets,
+      favorites, retweets and -show_user are available with v2.
+
+Examples:
+  $ twty -a work
+  $ twty -a work -reauthorize
+  $ twty -api v2 -u mattn_jp
+  $ TWTY_ACCESS_TOKEN=xxx TWTY_ACCESS_SECRET=yyy twty
+`,
+	"timeline": `Show timelines:
+  (no arguments): show home timeline.
+  -r: show replies.
+  -u USER: show user's timeline.
+  -l USER/LIST: show list's timeline. USER can be omitted for your lists.
+  -likes USER: show tweets liked by USER.
+  -all-accounts: show home timelines of all profiles merged.
+  -count NUMBER: show NUMBER tweets. pages are fetched until NUMBER tweets
+      are fetched.
+  -pages NUMBER: fetch at most NUMBER pages.
+  -since_id NUMBER, -max_id NUMBER: show tweets in the range of IDs.
+  -media-only: show only tweets with photos, videos or GIFs. direct URLs
+      of media (MP4 of the highest bitrate for videos) are shown under
+      tweets.
+  -no-replies: exclude replies.
+  -context: show the tweet replied to above each reply.
+  -lang LANG: show only tweets in LANG, like ja or en.
+  -no-rts: exclude retweets.
+  -sort KEY: sort tweets by "time" or "engagement". the most engaged tweet
+      is shown at the bottom.
+  -dedupe=false: show raw results without removing duplicated tweets.
+  -footer: show number of tweets and how old the oldest one is.
+  -new: show only tweets newer than the last seen in the timeline.
+  -reset-seen [TIMELINE]: reset seen markers of -new. TIMELINE is one of
+      home, replies, user:USER or list:USER/LIST, all if omitted.
+  -list-seen: show seen markers of -new.
+  -expect-min NUMBER: exit with error if fewer than NUMBER tweets are shown.
+  -show-ids: show maximum and minimum IDs of tweets (implied by -v). pass
+      them to -since_id or -max_id for the next page.
+  -json: show tweets as JSON.
+  -md: show tweets as Markdown.
+  -show-entities: show hashtags, mentions, URLs and media of tweets with
+      their indices.
+  -v: detail display, with counts of replies, retweets, quotes and
+      favorites.
+
+Examples:
+  $ twty -count 50
+  $ twty -u mattn_jp -count 200 -sort engagement -v
+  $ twty -u mattn_jp -count 1000 -pages 3
+  $ twty -new
+  $ twty -r -context
+  $ twty -reset-seen user:mattn_jp
+  $ twty -u mattn_jp -media-only
+  $ twty -u mattn_jp -no-replies -no-rts
+  $ twty -likes mattn_jp -count 200
+  $ twty -l mattn_jp/subtech -max_id 1234567890
+`,
+	"search": `Search tweets:
+  -s WORD: search tweets.
+  -v2: search with API v2 (requires BearerToken in configuration file).
+  -count NUMBER: show NUMBER tweets, fetching pages as needed.
+  -pages NUMBER: fetch at most NUMBER pages.
+  -since DATE, -until DATE: show tweets in the range of dates.
+  -lang LANG: search tweets in LANG, like ja or en.
+
+Examples:
+  $ twty -s golang
+  $ twty -s golang -v2 -count 100
+`,
+	"tweet": `Post tweets:
+  TEXT...: post TEXT. ID and URL of the posted tweet are shown.
+  -ff FILENAME: post utf-8 string from a file("-" means STDIN). a trailing
+      newline is removed, and newlines in the text are kept.
+  -trim: strip all spaces and newlines around the text of -ff.
+  -split: split TEXT or the text of -ff longer than 280 characters into a
+      thread on sentence or word boundaries, appending "(1/n)" counters.
+      the tweets are shown and confirmation is asked before posting, and
+      -dry-run shows them without posting.
+  -compose: compose a tweet with $EDITOR, lines starting with '#' are ignored.
+  -interactive: compose a tweet line by line, showing the length counted as
+      twitter does and characters left. "." on a line posts the tweet
+      unless it is too long, "/undo" removes the last line, and "/clear"
+      starts over.
+  -i ID: reply to the tweet.
+  -reply-all: with -i, prepend @mentions of the author and users mentioned
+      in the tweet (except you) to the reply.
+  -m FILE: attach media, can be specified multiple times.
+  -q ID, -quote ID: quote the tweet. ID can be URL of the tweet, and media
+      can be attached with -m. quoted tweets are shown under the tweets
+      in timelines.
+  -cw TEXT: prepend content warning like "CW: TEXT" to the tweet.
+  -reply-to WHO: limit who can reply to the tweet. WHO is everyone (default),
+      following (people you follow) or mentioned (people mentioned in the
+      tweet). the tweet is posted with API v2 even if -api is 1.1.
+  -lat LATITUDE, -long LONGITUDE: geotag the tweet with the coordinates,
+      which are shown to others. not available with API v2.
+  -place PLACE_ID: geotag the tweet with the place.
+  -place-search WORD: search places like "tokyo" and show their PLACE_IDs
+      to use with -place. places near -lat and -long are searched if they
+      are specified. -json shows them as JSON.
+  -poll OPTIONS: attach a poll of 2 to 4 comma separated options up to 25
+      characters. polls are posted with API v2 even if -api is 1.1, and
+      cannot be attached with media.
+  -poll-duration MINUTES: duration of the poll from 5 minutes to 7 days
+      (10080 minutes). 1440 (1 day) by default.
+  -template NAME: post a tweet from "Template.NAME" in configuration file.
+      {date}, {time}, {datetime} and {weekday} are replaced with current
+      time, and {KEY} is replaced with output of "TemplateCommand.KEY".
+      -dry-run shows the text without posting.
+  -stdin-json: post tweets described as a JSON object or an array of JSON
+      objects from STDIN, like {"text":"...","media":["a.jpg"],"reply_to":"123"}.
+      IDs of posted tweets are written as JSON. -dry-run validates them
+      without posting.
+  -delay DURATION: show the tweet and count down DURATION (like 10s) before
+      posting, so that it can be canceled with Ctrl-C. "PostDelay" in
+      configuration file sets the default, and -delay 0 disables it.
+  -verify: confirm the posted tweet is available.
+  -copy: copy URL of the posted tweet to clipboard (with pbcopy, clip,
+      wl-copy, xclip or xsel).
+  -detect-lang: show guessed language of the text instead of posting. set
+      LangDetectCommand in configuration file to use external command.
+  -count-chars: show length of the text (or STDIN) counted as twitter does
+      instead of posting. exits with 1 if it exceeds 280.
+
+  Tweets of the same text as one of the last 100 tweets are confirmed before
+  posting (unless -y), because twitter rejects duplicates.
+
+Examples:
+  $ twty hello world
+  $ twty -copy look at this
+  $ twty -detect-lang -compose
+  $ twty -count-chars < draft.txt
+  $ echo hello | twty -ff -
+  $ twty -split -dry-run -ff essay.txt
+  $ twty -i 1234567890 -compose
+  $ twty -i 1234567890 -reply-all sounds good
+  $ twty -interactive
+  $ twty -delay 10s hello wrold
+  $ twty -q 1234567890 -m photo.jpg me too
+  $ twty -reply-to following hot take
+  $ twty -place-search shibuya
+  $ twty -place 1234567890abcdef in shibuya
+  $ twty -poll "tabs,spaces" -poll-duration 60 which do you use?
+  $ twty -quote https://twitter.com/mattn_jp/status/1234567890 nice
+  $ twty -template morning -dry-run
+  $ echo '{"text":"hello"}' | twty -stdin-json
+`,
+	"media": `Upload media:
+  -m FILE: upload media, can be specified multiple times. Videos, GIFs and
+      files larger than 5MB are uploaded in chunks of 5MB, and files
+      uploaded in 24 hours are not uploaded again. up to 4 files are
+      uploaded at once, and attached in the order of -m. FILE can be URL of an
+      image or a video, which is downloaded before uploading. types of
+      media are detected from their contents, and animated GIFs are
+      uploaded as GIFs while GIFs without animation are uploaded as photos.
+  -alt TEXT: set alt text (up to 1000 characters) of the media, can be
+      specified multiple times, matched to -m in the same order. -alt ""
+      skips the media. the tweet is not posted if alt text cannot be set.
+  -sensitive: mark the media as possibly sensitive, so that they are hidden
+      behind a warning. not available with API v2, -poll and -reply-to.
+  -strip-exif: remove EXIF, XMP and IPTC metadata, which may contain GPS
+      location, from JPEG and PNG images before uploading. orientation of
+      the image is kept.
+  -max-upload-size SIZE: re-encode JPEG and PNG images larger than SIZE
+      (like 5MB or 500KB) as JPEG, lowering quality and scaling down until
+      they fit.
+  -max-dimension PIXELS: scale down JPEG and PNG images wider or taller
+      than PIXELS.
+  -no-wait: do not wait for processing of uploaded video. posting the tweet
+      may fail if the processing fails.
+
+  Progress of uploading files larger than 1MB is shown when stdout is a
+  terminal, unless -json is specified.
+
+Examples:
+  $ twty -m cat.jpg -m dog.jpg cute
+  $ twty -m cat.jpg -alt "a cat sleeping on a keyboard" my desk
+  $ twty -m sketch.png -sensitive new artwork
+  $ twty -m IMG_0001.jpg -strip-exif -max-upload-size 5MB -max-dimension 4096 trip
+  $ twty -m movie.mp4 -no-wait look at this
+  $ twty -m https://example.com/pic.jpg look at this
+`,
+	"bookmark": `Bookmarks (requires OAuth 2.0):
+  -bookmark ID: add the tweet to bookmarks.
+  -unbookmark ID: remove the tweet from bookmarks.
+  -bookmarks: show bookmarks. -count NUMBER pages through NUMBER bookmarks.
+
+  Bookmarks are available only with OAuth 2.0. Set ClientID (and
+  OAuth2ClientSecret for confidential clients) of your app in configuration
+  file, and register http://127.0.0.1/callback (or OAuth2RedirectURL) as
+  callback URL of the app. Paste the URL redirected to after authorization.
+
+Examples:
+  $ twty -bookmark 1234567890
+  $ twty -bookmarks -count 100
+`,
+	"list": `Manage lists:
+  -list-create NAME: create a list.
+  -list-update LIST: update your list. LIST is USER/LIST, LIST of yours or
+      the ID of the list.
+  -list-destroy LIST: delete your list. the list is shown and confirmation
+      is asked before deleting.
+  -list-name NAME: rename the list with -list-update.
+  -list-description TEXT: set description of the list.
+  -private, -public: make the list private or public. lists are created as
+      public by default, and -list-update keeps the mode without them.
+  -y: delete without confirmation.
+
+Examples:
+  $ twty -list-create golang -private -list-description "gophers"
+  $ twty -list-update golang -list-name go -public
+  $ twty -list-destroy go
+`,
+	"delete": `Delete tweets:
+  -delete ID: delete your tweet. the tweet is shown and confirmation is
+      asked before deleting. ID can be URL of the tweet.
+  -delete -: delete tweets of IDs read from STDIN, separated by spaces or
+      newlines. -y is required.
+  -y: delete without confirmation.
+
+Examples:
+  $ twty -delete 1234567890
+  $ cat ids.txt | twty -delete - -y
+`,
+	"draft": `Manage drafts:
+  -draft save [TEXT]: save TEXT as a draft, or compose it with $EDITOR if
+      TEXT is omitted. media of -m and the tweet of -i are saved together.
+  -draft list: show drafts with their IDs.
+  -draft edit ID: edit text of the draft with $EDITOR.
+  -draft post ID: post the draft, and delete it.
+  -draft delete ID: delete the draft.
+
+  Drafts are stored next to the configuration file, so that they are
+  shared with machines sharing the configuration directory.
+
+Examples:
+  $ twty -draft save -m cat.jpg look at this
+  $ twty -draft list
+  $ twty -draft post 1
+`,
+	"queue": `Queue tweets:
+  -queue TEXT: queue the tweet to post later, with media of -m and the
+      tweet of -i. nothing is sent to twitter, so it works offline.
+  -flush: post queued tweets in order, waiting 10 seconds between posts.
+      tweets failed to post stay in the queue with the error.
+  -flush -dry-run: show queued tweets with errors of the last attempts.
+
+Examples:
+  $ twty -queue -m photo.jpg at the summit
+  $ twty -flush -dry-run
+  $ twty -flush
+`,
+	"schedule": `Schedule tweets:
+  -schedule TIME TEXT: schedule the tweet at TIME, with media of -m and the
+      tweet of -i. TIME is like "2024-07-01 09:00", "09:00" (the next 9
+      o'clock) or "+2h" (2 hours later). TIME is in the time zone of
+      "TimeZone" in configuration file (ex: Asia/Tokyo), or local time zone,
+      unless it has offset like "2024-07-01T09:00:00+09:00".
+  -schedule list: show scheduled tweets with their IDs.
+  -schedule cancel ID: cancel the scheduled tweet.
+  -run-scheduler: post scheduled tweets whose time has arrived, and exit.
+      run it with cron to post scheduled tweets.
+  -scheduler: keep running and post scheduled tweets at their time, checking
+      them every minute.
+
+  Tweets failed to post are retried up to 3 times, and shown with the error
+  by "-schedule list".
+
+Examples:
+  $ twty -schedule "2024-07-01 09:00" good morning
+  $ twty -schedule +30m -m photo.jpg lunch
+  $ twty -schedule list
+  $ twty -schedule cancel 2
+  $ crontab -l
+  * * * * * twty -run-scheduler
+`,
+	"favorite": `Favorite tweets:
+  -f ID: favorite the tweet.
+  -unfav ID: undo favorite of the tweet.
+
+Examples:
+  $ twty -f 1234567890
+  $ twty -unfav 1234567890
+`,
+	"retweet": `Retweet tweets:
+  -i ID: retweet the tweet when no text is specified.
+
+Examples:
+  $ twty -i 1234567890
+`,
+	"status": `Show a tweet:
+  -status ID: show the tweet with user, time, counts and entities. ID can
+      be a permalink like https://twitter.com/mattn_jp/status/1234567890.
+  -thread ID: show the thread containing the tweet as a tree. replies are
+      searched in recent tweets only.
+  -quotes ID: show tweets quoting the tweet. recent tweets are searched
+      unless API v2 is used.
+  -open ID|@USER: open the tweet or the user in the browser.
+  -open-last: open the tweet you posted last in the browser.
+  -embed ID: show embeddable HTML of the tweet with oEmbed.
+  -json: show the tweet (or oEmbed of -embed) as JSON.
+
+  IDs of tweets given to -status, -f, -i, -q, -delete and so on can be
+  URLs like https://twitter.com/mattn_jp/status/1234567890 (or x.com, and
+  t.co links which are resolved).
+
+Examples:
+  $ twty -status 1234567890
+  $ twty -quotes 1234567890
+  $ twty -thread 1234567890
+  $ twty -open @mattn_jp
+  $ twty -open-last
+  $ twty -embed 1234567890 >> post.html
+  $ twty -status https://twitter.com/mattn_jp/status/1234567890
+`,
+	"user": `Show users:
+  -show_user USER: show user profile.
+  -show_user_id ID: show user profile of user ID, which does not change
+      when the user is renamed.
+  -search_user WORD: search users.
+  -friendship USER: show friendship between you and USER.
+  -relationship USER1 USER2: show relationship between USER1 and USER2 as
+      a matrix of following, follow request, notifications, blocking and
+      muting in each direction. requests, notifications, blocking and
+      muting are known only when USER1 is you.
+  -mutuals USER1 USER2: show users who follow both USER1 and USER2.
+      followers are fetched as IDs, waiting for the rate limit to be reset.
+  -blocked, -blocks: show users you block.
+  -block USER, -unblock USER: block or unblock USER. can be specified
+      multiple times, or separated by commas. "-" reads screen names
+      separated by lines from STDIN, to deal with a wave of spam accounts.
+  -muted, -mutes: show users you mute.
+  -mute USER, -unmute USER: mute or unmute USER, in the same way as
+      -block.
+  -report USER: report USER as spam, in the same way as -block.
+  -perform-block: block users reported with -report as well.
+  -followers USER: show followers of USER, or yourself with "me". pages
+      are fetched as needed, waiting for the rate limit to be reset.
+  -following USER: show users who USER (or yourself with "me") follows,
+      in the same way as -followers.
+  -count NUMBER: show at most NUMBER followers (or users followed).
+  -names: show only screen names of users, one per line.
+  -csv: show users as CSV.
+  -lookup USER...: show users of screen names or IDs. screen names of
+      only digits need "@". they are read from STDIN one per line without
+      arguments. users are fetched 100 at once.
+  -lookup-file FILE: show users of screen names or IDs in FILE, one per
+      line.
+  -follow USER, -unfollow USER: follow or unfollow USER, and show the
+      friendship after that. can be specified multiple times, or
+      separated by commas to follow (or unfollow) several users.
+  -follow-file FILE: follow users in FILE. each line has a screen name or
+      a user ID. screen names of only digits need "@". empty lines and
+      lines starting with "#" are ignored.
+  -interval DURATION: interval between follows, blocks and so on, to stay
+      under rate limits (default 1s). the rate limits are waited for to be
+      reset if they are hit anyway.
+  -result FILE: write the result (or error) of each user to FILE, which
+      can be read with -follow-file again.
+  -follow-back: follow your followers who you do not follow yet. asks
+      confirmation before following unless -y. follows are paced with
+      -interval, waiting for the rate limit to be reset if it is hit.
+  -unfollow-nonmutual: unfollow users who do not follow you back. asks
+      confirmation before unfollowing unless -y. unfollows are paced with
+      -interval, and the users left are kept next to the configuration
+      file so that it is resumed by running it again if it is interrupted.
+  -snapshot-followers: save IDs of your followers next to the
+      configuration file, and show who followed (+) and unfollowed (-) you
+      since the last snapshot with times of the snapshots. users who
+      cannot be looked up like suspended ones are shown with IDs (?).
+  -dry-run: show users to follow back (or unfollow) without following.
+  -follow-requests, -requests: show pending follow requests (for
+      protected accounts).
+  -accept USER, -deny USER: accept or deny follow request of USER, in the
+      same way as -block.
+  -limit NUMBER: show (or follow) at most NUMBER users.
+  -json: show users as JSON.
+  -v: detail display.
+
+Examples:
+  $ twty -show_user mattn_jp -v
+  $ twty -show_user_id 12345
+  $ twty -friendship mattn_jp
+  $ twty -relationship mattn_jp golang
+  $ twty -mutuals mattn_jp golang -names
+  $ twty -follow mattn_jp,golang
+  $ twty -follow-file list.txt -interval 10s -result result.txt
+  $ twty -unfollow-nonmutual -limit 100 -interval 5s
+  $ twty -snapshot-followers
+  $ twty -lookup-file ids.txt -csv > users.csv
+  $ twty -followers mattn_jp -count 500 -names
+  $ twty -following me -json
+  $ twty -blocked -limit 100
+  $ twty -block - < spammers.txt
+  $ twty -mute noisy_bot,another_bot
+  $ twty -report spam_bot -perform-block
+  $ twty -requests -names | twty -accept -
+`,
+	"dry-run": `Check what will be done:
+  -dry-run, -n: show API calls which change anything, like posting tweets,
+      favorites, retweets and deleting tweets, with their parameters
+      instead of sending them. media are not uploaded, and their IDs are
+      shown as the file names like <cat.jpg>.
+  -confirm: show API calls which change anything, and ask confirmation
+      before sending each of them.
+
+Examples:
+  $ twty -n -m cat.jpg -i 1234567890 cute
+  $ twty -confirm -f 1234567890
+`,
+	"raw": `Call arbitrary API (advanced, unsupported):
+  -raw METHOD:ENDPOINT: call the API and dump JSON.
+  -param KEY=VALUE: parameter for -raw, can be specified multiple times.
+
+Examples:
+  $ twty -raw GET:1.1/trends/place.json -param id=1
+`,
+}
+
+// commandNames returns sorted names of commands
+func commandNames() []string {
+	var names []string
+	for name := range commandHelp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// showCommandHelp prints detailed usage of the command
+func showCommandHelp(name string) {
+	help, ok := commandHelp[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q: available commands are %s\n", name, strings.Join(commandNames(), ", "))
+		os.Exit(2)
+	}
+	fmt.Print(help)
+	os.Exit(0)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// _HistorySize is the number of recently posted tweets kept to detect
+// duplicates
+const _HistorySize = 100
+
+// postedTweet hold hash of the text of a posted tweet
+type postedTweet struct {
+	Hash   string    `json:"hash"`
+	ID     string    `json:"id_str"`
+	Posted time.Time `json:"posted"`
+}
+
+// postHistory hold hashes of recently posted tweets, as twitter rejects
+// tweets of the same text as a recent one
+type postHistory struct {
+	file  string
+	Posts []postedTweet
+}
+
+func loadPostHistory(file string) (*postHistory, error) {
+	h := &postHistory{file: file}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &h.Posts); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %v: %v", file, err)
+	}
+	return h, nil
+}
+
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// find returns the recent tweet of the same text, or nil
+func (h *postHistory) find(text string) *postedTweet {
+	hash := textHash(text)
+	for i := len(h.Posts) - 1; i >= 0; i-- {
+		if h.Posts[i].Hash == hash {
+			return &h.Posts[i]
+		}
+	}
+	return nil
+}
+
+// add records the posted tweet, dropping old ones
+func (h *postHistory) add(text string, id string) {
+	h.Posts = append(h.Posts, postedTweet{Hash: textHash(text), ID: id, Posted: time.Now()})
+	if len(h.Posts) > _HistorySize {
+		h.Posts = h.Posts[len(h.Posts)-_HistorySize:]
+	}
+}
+
+func (h *postHistory) save() error {
+	b, err := json.MarshalIndent(h.Posts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.file, b, 0600)
+}
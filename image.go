@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// imageOptions hold how to preprocess images before uploading
+type imageOptions struct {
+	// stripExif removes EXIF (including GPS location), XMP and IPTC
+	// metadata except orientation
+	stripExif bool
+	// maxSize is the maximum size of images in bytes. larger images are
+	// re-encoded as JPEG, and scaled down if needed.
+	maxSize int64
+	// maxDimension is the maximum width and height of images in pixels
+	maxDimension int
+}
+
+func (o imageOptions) enabled() bool {
+	return o.stripExif || o.maxSize > 0 || o.maxDimension > 0
+}
+
+// imagePreprocess is set with -strip-exif, -max-upload-size and -max-dimension
+var imagePreprocess imageOptions
+
+// parseSize parses size like "5MB", "500KB" or "1024" in bytes
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		n      int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	t := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(t, u.suffix) {
+			t, unit = strings.TrimSpace(strings.TrimSuffix(t, u.suffix)), u.n
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(t, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// preprocessImages preprocesses JPEG and PNG images with imagePreprocess, and
+// returns paths replacing the files with temporary files of processed ones.
+// cleanup removes the temporary files.
+func preprocessImages(files []string) ([]string, func(), error) {
+	var temps []string
+	cleanup := func() {
+		for _, temp := range temps {
+			os.Remove(temp)
+		}
+	}
+	if !imagePreprocess.enabled() {
+		return files, cleanup, nil
+	}
+	processed := make([]string, len(files))
+	for i, file := range files {
+		processed[i] = file
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		out, ext, err := preprocessImage(b, imagePreprocess)
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("cannot process %v: %v", file, err)
+		}
+		if out == nil {
+			continue
+		}
+		f, err := ioutil.TempFile("", "twty-*"+ext)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		temps = append(temps, f.Name())
+		_, err = f.Write(out)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, cleanup, err
+		}
+		processed[i] = f.Name()
+	}
+	return processed, cleanup, nil
+}
+
+// preprocessImage returns the processed image and its extension, or nil if
+// the image is not changed. Images other than JPEG and PNG are not changed.
+func preprocessImage(b []byte, opt imageOptions) ([]byte, string, error) {
+	typ := http.DetectContentType(b)
+	if typ != "image/jpeg" && typ != "image/png" {
+		return nil, "", nil
+	}
+	ext := ".jpg"
+	if typ == "image/png" {
+		ext = ".png"
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", err
+	}
+	orientation := 1
+	if typ == "image/jpeg" {
+		orientation = jpegOrientation(b)
+	}
+
+	var out []byte
+	if opt.stripExif {
+		if typ == "image/jpeg" {
+			out = stripJPEGMetadata(b, orientation)
+		} else {
+			// encoding PNG again drops all metadata chunks losslessly
+			img, err := png.Decode(bytes.NewReader(b))
+			if err != nil {
+				return nil, "", err
+			}
+			var buf bytes.Buffer
+			if err = png.Encode(&buf, img); err != nil {
+				return nil, "", err
+			}
+			out = buf.Bytes()
+		}
+	}
+	tooLarge := opt.maxDimension > 0 && (cfg.Width > opt.maxDimension || cfg.Height > opt.maxDimension)
+	current := b
+	if out != nil {
+		current = out
+	}
+	if !tooLarge && (opt.maxSize <= 0 || int64(len(current)) <= opt.maxSize) {
+		return out, ext, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", err
+	}
+	if tooLarge {
+		img = resizeImage(img, opt.maxDimension)
+		if typ == "image/png" {
+			// keep transparency of PNG if it fits
+			var buf bytes.Buffer
+			if err = png.Encode(&buf, img); err != nil {
+				return nil, "", err
+			}
+			if opt.maxSize <= 0 || int64(buf.Len()) <= opt.maxSize {
+				return buf.Bytes(), ext, nil
+			}
+		}
+	}
+	// lower quality first, and then scale down until it fits
+	quality := 90
+	for {
+		out, err = encodeJPEG(img, quality, orientation)
+		if err != nil {
+			return nil, "", err
+		}
+		if opt.maxSize <= 0 || int64(len(out)) <= opt.maxSize {
+			return out, ".jpg", nil
+		}
+		if quality > 60 {
+			quality -= 10
+			continue
+		}
+		bounds := img.Bounds()
+		if bounds.Dx() < 64 || bounds.Dy() < 64 {
+			return nil, "", fmt.Errorf("cannot make the image smaller than %d bytes", opt.maxSize)
+		}
+		max := bounds.Dx()
+		if bounds.Dy() > max {
+			max = bounds.Dy()
+		}
+		img = resizeImage(img, max*3/4)
+	}
+}
+
+// encodeJPEG encodes the image as JPEG on white background, with EXIF of the
+// orientation unless it is normal
+func encodeJPEG(img image.Image, quality int, orientation int) ([]byte, error) {
+	bg := image.NewRGBA(img.Bounds())
+	draw.Draw(bg, bg.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(bg, bg.Bounds(), img, img.Bounds().Min, draw.Over)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, bg, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return stripJPEGMetadata(buf.Bytes(), orientation), nil
+}
+
+// resizeImage scales down the image to fit in max x max pixels, averaging
+// source pixels of each destination pixel
+func resizeImage(img image.Image, max int) image.Image {
+	src := img.Bounds()
+	w, h := src.Dx(), src.Dy()
+	if w <= max && h <= max {
+		return img
+	}
+	dw, dh := max, h*max/w
+	if h > w {
+		dw, dh = w*max/h, max
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := src.Min.Y+y*h/dh, src.Min.Y+(y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := src.Min.X+x*w/dw, src.Min.X+(x+1)*w/dw
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			if n > 0 {
+				dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+			}
+		}
+	}
+	return dst
+}
+
+// jpegSegments calls f with marker and payload of each segment of the JPEG
+// before the image data. It returns offset of the image data.
+func jpegSegments(b []byte, f func(marker byte, payload []byte)) int {
+	i := 2 // SOI
+	for i+4 <= len(b) && b[i] == 0xFF {
+		marker := b[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if n < 2 || i+2+n > len(b) {
+			break
+		}
+		f(marker, b[i+4:i+2+n])
+		i += 2 + n
+	}
+	return i
+}
+
+// jpegOrientation returns the orientation in EXIF of the JPEG, or 1 (normal)
+func jpegOrientation(b []byte) int {
+	orientation := 1
+	jpegSegments(b, func(marker byte, payload []byte) {
+		if marker != 0xE1 || !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return
+		}
+		tiff := payload[6:]
+		if len(tiff) < 8 {
+			return
+		}
+		var order binary.ByteOrder = binary.BigEndian
+		if string(tiff[:2]) == "II" {
+			order = binary.LittleEndian
+		}
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd+2 > len(tiff) {
+			return
+		}
+		count := int(order.Uint16(tiff[ifd:]))
+		for e := ifd + 2; e+12 <= len(tiff) && count > 0; e, count = e+12, count-1 {
+			if order.Uint16(tiff[e:]) == 0x0112 {
+				if o := int(order.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+					orientation = o
+				}
+				return
+			}
+		}
+	})
+	return orientation
+}
+
+// stripJPEGMetadata removes EXIF, XMP (APP1) and IPTC (APP13) segments from
+// the JPEG without encoding it again. EXIF of only the orientation is added
+// unless it is normal, so that the image is not shown rotated.
+func stripJPEGMetadata(b []byte, orientation int) []byte {
+	var exif []byte
+	if orientation > 1 {
+		exif = []byte("Exif\x00\x00" +
+			"MM\x00\x2a\x00\x00\x00\x08" + // big endian TIFF header
+			"\x00\x01" + // 1 entry
+			"\x01\x12\x00\x03\x00\x00\x00\x01" + // orientation, SHORT, 1 value
+			string([]byte{0, byte(orientation), 0, 0}) +
+			"\x00\x00\x00\x00") // no next IFD
+	}
+	var buf bytes.Buffer
+	writeSegment := func(marker byte, payload []byte) {
+		buf.Write([]byte{0xFF, marker, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)})
+		buf.Write(payload)
+	}
+	buf.Write(b[:2])
+	end := jpegSegments(b, func(marker byte, payload []byte) {
+		// EXIF follows JFIF (APP0) which must be the first segment
+		if exif != nil && marker != 0xE0 {
+			writeSegment(0xE1, exif)
+			exif = nil
+		}
+		if marker != 0xE1 && marker != 0xED {
+			writeSegment(marker, payload)
+		}
+	})
+	if exif != nil {
+		writeSegment(0xE1, exif)
+	}
+	buf.Write(b[end:])
+	return buf.Bytes()
+}
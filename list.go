@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// List hold information about a list
+type List struct {
+	ID          int64  `json:"id"`
+	IDStr       string `json:"id_str"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	FullName    string `json:"full_name"`
+	Mode        string `json:"mode"`
+	Description string `json:"description"`
+	MemberCount int    `json:"member_count"`
+	User        struct {
+		ScreenName string `json:"screen_name"`
+	} `json:"user"`
+}
+
+// listParams returns parameters of the list given as ID, "USER/LIST" or
+// "LIST" of screenName
+func listParams(list string, screenName string) map[string]string {
+	if _, err := strconv.ParseInt(list, 10, 64); err == nil {
+		return map[string]string{"list_id": list}
+	}
+	part := strings.SplitN(strings.TrimPrefix(list, "@"), "/", 2)
+	if len(part) == 1 {
+		part = []string{screenName, part[0]}
+	}
+	return map[string]string{"owner_screen_name": part[0], "slug": part[1]}
+}
+
+// listMode returns mode of lists for -private and -public, or empty to keep
+// the mode
+func listMode(private bool, public bool) (string, error) {
+	if private && public {
+		return "", fmt.Errorf("cannot use -private with -public")
+	}
+	if private {
+		return "private", nil
+	}
+	if public {
+		return "public", nil
+	}
+	return "", nil
+}
+
+func showList(list List, asjson bool) {
+	if asjson {
+		json.NewEncoder(os.Stdout).Encode(list)
+		os.Stdout.Sync()
+		return
+	}
+	fmt.Printf("%s\t%s\t%s\t%d members\n", list.IDStr, list.FullName, list.Mode, list.MemberCount)
+	if list.Description != "" {
+		fmt.Println("  " + html.UnescapeString(replacer.Replace(list.Description)))
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	_OAuth2AuthorizeURL = "https://twitter.com/i/oauth2/authorize"
+	_OAuth2TokenURL     = "https://api.twitter.com/2/oauth2/token"
+	_OAuth2RedirectURL  = "http://127.0.0.1/callback"
+	_OAuth2Scope        = "tweet.read users.read bookmark.read bookmark.write offline.access"
+)
+
+// OAuth2Token hold information about access token of OAuth 2.0
+type OAuth2Token struct {
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+// getOAuth2Token returns access token of OAuth 2.0 for the endpoints which
+// require OAuth 2.0 user context, like bookmarks, for getAccessToken. It
+// refreshes the expired token, or authorizes with PKCE if no token is
+// available. The second value is true when config is updated.
+func getOAuth2Token(config map[string]string) (string, bool, error) {
+	if config["ClientID"] == "" {
+		return "", false, fmt.Errorf("ClientID of OAuth 2.0 is required in configuration file")
+	}
+	if token := config["OAuth2AccessToken"]; token != "" {
+		expires, err := time.Parse(time.RFC3339, config["OAuth2Expires"])
+		if err == nil && time.Now().Before(expires) {
+			return token, false, nil
+		}
+	}
+
+	var res OAuth2Token
+	var err error
+	if refresh := config["OAuth2RefreshToken"]; refresh != "" {
+		res, err = requestOAuth2Token(config, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refresh},
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cannot refresh token, authorize again:", err)
+		}
+	}
+	if res.AccessToken == "" {
+		res, err = authorizeOAuth2(config)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	config["OAuth2AccessToken"] = res.AccessToken
+	config["OAuth2RefreshToken"] = res.RefreshToken
+	config["OAuth2Expires"] = time.Now().Add(time.Duration(res.ExpiresIn) * time.Second).Format(time.RFC3339)
+	return res.AccessToken, true, nil
+}
+
+// authorizeOAuth2 asks user to authorize the app with the browser, and
+// exchanges the code in the redirected URL for the token.
+func authorizeOAuth2(config map[string]string) (OAuth2Token, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	redirect := config["OAuth2RedirectURL"]
+	if redirect == "" {
+		redirect = _OAuth2RedirectURL
+	}
+	param := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {config["ClientID"]},
+		"redirect_uri":          {redirect},
+		"scope":                 {_OAuth2Scope},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	err = openURL(_OAuth2AuthorizeURL+"?"+param.Encode(), prompts["PromptAuthorize"])
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+
+	fmt.Print(prompts["PromptCode"])
+	stdin := bufio.NewScanner(os.Stdin)
+	if !stdin.Scan() {
+		return OAuth2Token{}, fmt.Errorf("canceled")
+	}
+	code := strings.TrimSpace(stdin.Text())
+	if u, err := url.Parse(code); err == nil && u.RawQuery != "" {
+		query := u.Query()
+		if query.Get("state") != state {
+			return OAuth2Token{}, fmt.Errorf("state mismatch")
+		}
+		if e := query.Get("error"); e != "" {
+			return OAuth2Token{}, fmt.Errorf("cannot authorize: %v", e)
+		}
+		code = query.Get("code")
+	}
+	if code == "" {
+		return OAuth2Token{}, fmt.Errorf("no code given")
+	}
+	return requestOAuth2Token(config, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirect},
+		"code_verifier": {verifier},
+	})
+}
+
+// requestOAuth2Token requests the token to the token endpoint. The client is
+// authenticated with ClientSecret if it is a confidential client.
+func requestOAuth2Token(config map[string]string, param url.Values) (OAuth2Token, error) {
+	var res OAuth2Token
+	if config["OAuth2ClientSecret"] == "" {
+		param.Set("client_id", config["ClientID"])
+	}
+	req, err := http.NewRequest(http.MethodPost, _OAuth2TokenURL, strings.NewReader(param.Encode()))
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if secret := config["OAuth2ClientSecret"]; secret != "" {
+		req.SetBasicAuth(config["ClientID"], secret)
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+	if err = decodeResponse(resp, &res); err != nil {
+		return res, err
+	}
+	if res.AccessToken == "" {
+		if res.Error != "" {
+			return res, fmt.Errorf("%s: %s", res.Error, res.Description)
+		}
+		return res, fmt.Errorf("no access token returned")
+	}
+	return res, nil
+}
+
+// randomString returns URL safe random string of n bytes
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// _FlushInterval is interval between posts of queued tweets to avoid rate limit
+const _FlushInterval = 10 * time.Second
+
+// QueuedTweet hold information about a tweet queued to post later with -flush
+type QueuedTweet struct {
+	Text     string    `json:"text"`
+	Media    []string  `json:"media,omitempty"`
+	ReplyTo  string    `json:"reply_to,omitempty"`
+	Queued   time.Time `json:"queued"`
+	Attempts int       `json:"attempts,omitempty"`
+	// Error is the error of the last attempt to post
+	Error string `json:"error,omitempty"`
+}
+
+// tweetQueue hold tweets queued in the file next to the configuration file
+type tweetQueue struct {
+	file   string
+	Tweets []QueuedTweet
+}
+
+func loadTweetQueue(file string) (*tweetQueue, error) {
+	q := &tweetQueue{file: file}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return q, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &q.Tweets); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %v: %v", file, err)
+	}
+	return q, nil
+}
+
+func (q *tweetQueue) save() error {
+	b, err := json.MarshalIndent(q.Tweets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(q.file, b, 0600)
+}
+
+// flush posts queued tweets in order, waiting _FlushInterval between posts.
+// Tweets failed to post are kept in the queue with the error. It returns the
+// number of posted tweets.
+func (q *tweetQueue) flush(post func(QueuedTweet) (Tweet, error)) (int, error) {
+	tweets := q.Tweets
+	var failed []QueuedTweet
+	posted := 0
+	for i, queued := range tweets {
+		if i > 0 && !dryRunWrites {
+			time.Sleep(_FlushInterval)
+		}
+		tweet, err := post(queued)
+		if err == errSkipped {
+			// the queue is kept as it is with -dry-run
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot post queued tweet %d: %v\n", i+1, err)
+			queued.Attempts++
+			queued.Error = err.Error()
+			failed = append(failed, queued)
+		} else {
+			fmt.Println("tweeted:", tweet.Identifier)
+			posted++
+		}
+		// store the progress not to post tweets twice if interrupted
+		q.Tweets = append(append([]QueuedTweet{}, failed...), tweets[i+1:]...)
+		if err = q.save(); err != nil {
+			return posted, err
+		}
+	}
+	return posted, nil
+}
+
+// showQueue prints the queued tweets with errors of the last attempts
+func showQueue(tweets []QueuedTweet, asjson bool) {
+	if asjson {
+		json.NewEncoder(os.Stdout).Encode(tweets)
+		return
+	}
+	for i, queued := range tweets {
+		fmt.Printf("%d\t%s\t%s\n", i+1, queued.Queued.Local().Format("2006-01-02 15:04"), replacer.Replace(queued.Text))
+		for _, file := range queued.Media {
+			fmt.Printf("\tmedia %s\n", file)
+		}
+		if queued.Error != "" {
+			fmt.Printf("\terror (%d attempts): %s\n", queued.Attempts, queued.Error)
+		}
+	}
+}
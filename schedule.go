@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// _SchedulerInterval is interval of the scheduler to check scheduled tweets
+	_SchedulerInterval = time.Minute
+	// _MaxScheduleAttempts is number of attempts to post a scheduled tweet
+	// before giving up
+	_MaxScheduleAttempts = 3
+)
+
+// scheduleLayouts are layouts accepted by -schedule. Times without offset
+// are in the time zone of TimeZone in configuration file.
+var scheduleLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04 -0700",
+	"2006/01/02 15:04",
+}
+
+// ScheduledTweet hold information about a tweet scheduled to post at the time
+type ScheduledTweet struct {
+	ID       int       `json:"id"`
+	Text     string    `json:"text"`
+	Media    []string  `json:"media,omitempty"`
+	ReplyTo  string    `json:"reply_to,omitempty"`
+	At       time.Time `json:"at"`
+	Created  time.Time `json:"created"`
+	Attempts int       `json:"attempts,omitempty"`
+	// Error is the error of the last attempt to post
+	Error string `json:"error,omitempty"`
+}
+
+// schedule hold scheduled tweets stored in the file next to the configuration file
+type schedule struct {
+	file   string
+	NextID int              `json:"next_id"`
+	Tweets []ScheduledTweet `json:"tweets"`
+}
+
+func loadSchedule(file string) (*schedule, error) {
+	s := &schedule{file: file, NextID: 1}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %v: %v", file, err)
+	}
+	return s, nil
+}
+
+// add stores the tweet with a new ID, and returns the ID
+func (s *schedule) add(tweet ScheduledTweet) int {
+	tweet.ID = s.NextID
+	tweet.Created = time.Now()
+	s.NextID++
+	s.Tweets = append(s.Tweets, tweet)
+	return tweet.ID
+}
+
+// find returns the scheduled tweet of the ID given as string
+func (s *schedule) find(id string) (*ScheduledTweet, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule ID: %q", id)
+	}
+	for i := range s.Tweets {
+		if s.Tweets[i].ID == n {
+			return &s.Tweets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("scheduled tweet %d not found", n)
+}
+
+func (s *schedule) remove(id int) {
+	for i := range s.Tweets {
+		if s.Tweets[i].ID == id {
+			s.Tweets = append(s.Tweets[:i], s.Tweets[i+1:]...)
+			return
+		}
+	}
+}
+
+// due returns tweets whose time has arrived, except those given up
+func (s *schedule) due(now time.Time) []ScheduledTweet {
+	var tweets []ScheduledTweet
+	for _, tweet := range s.Tweets {
+		if !tweet.At.After(now) && tweet.Attempts < _MaxScheduleAttempts {
+			tweets = append(tweets, tweet)
+		}
+	}
+	return tweets
+}
+
+func (s *schedule) save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.file, b, 0600)
+}
+
+// scheduleLocation returns the time zone of the name like "Asia/Tokyo", or
+// local time zone if name is empty.
+func scheduleLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// parseScheduleTime parses time of -schedule. It accepts layouts of
+// scheduleLayouts, "15:04" for the next time of the day, and durations
+// like "+1h30m" from now.
+func parseScheduleTime(s string, loc *time.Location, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "+") {
+		d, err := time.ParseDuration(s[1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration: %q", s)
+		}
+		return now.Add(d), nil
+	}
+	if t, err := time.ParseInLocation("15:04", s, loc); err == nil {
+		y, m, d := now.In(loc).Date()
+		at := time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	for _, layout := range scheduleLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %q (ex: \"2024-07-01 09:00\", \"09:00\" or \"+2h\")", s)
+}
+
+// showSchedule prints the scheduled tweets in the time zone
+func showSchedule(tweets []ScheduledTweet, loc *time.Location, asjson bool) {
+	if asjson {
+		json.NewEncoder(os.Stdout).Encode(tweets)
+		return
+	}
+	for _, tweet := range tweets {
+		fmt.Printf("%d\t%s\t%s\n", tweet.ID, tweet.At.In(loc).Format("2006-01-02 15:04 MST"), replacer.Replace(tweet.Text))
+		if tweet.ReplyTo != "" {
+			fmt.Printf("\treply to %s\n", tweet.ReplyTo)
+		}
+		for _, file := range tweet.Media {
+			fmt.Printf("\tmedia %s\n", file)
+		}
+		if tweet.Error != "" {
+			fmt.Printf("\terror (%d attempts): %s\n", tweet.Attempts, tweet.Error)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// seenMarkers hold IDs of the last seen tweets of timelines for -new. Names
+// of timelines are "home", "replies", "user:USER" and "list:USER/LIST".
+type seenMarkers struct {
+	file string
+	ids  map[string]int64
+}
+
+// stateFile returns path of the file which stores the state of the profile
+// next to the configuration file (ex: seen-work.json for the profile work)
+func stateFile(configFile string, profile string, name string) string {
+	base := name + ".json"
+	if profile != "" {
+		base = name + "-" + profile + ".json"
+	}
+	return filepath.Join(filepath.Dir(configFile), base)
+}
+
+func loadSeenMarkers(file string) (*seenMarkers, error) {
+	m := &seenMarkers{file: file, ids: map[string]int64{}}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &m.ids); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %v: %v", file, err)
+	}
+	return m, nil
+}
+
+func (m *seenMarkers) get(timeline string) int64 {
+	return m.ids[timeline]
+}
+
+// mark records the newest ID of the tweets as the last seen
+func (m *seenMarkers) mark(timeline string, tweets []Tweet) {
+	for _, tweet := range tweets {
+		id, err := strconv.ParseInt(tweet.Identifier, 10, 64)
+		if err == nil && id > m.ids[timeline] {
+			m.ids[timeline] = id
+		}
+	}
+}
+
+// reset clears the marker of the timeline, or all markers if timeline is empty
+func (m *seenMarkers) reset(timeline string) {
+	if timeline == "" {
+		m.ids = map[string]int64{}
+		return
+	}
+	delete(m.ids, timeline)
+}
+
+func (m *seenMarkers) names() []string {
+	var names []string
+	for name := range m.ids {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *seenMarkers) save() error {
+	b, err := json.MarshalIndent(m.ids, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(m.file, b, 0600)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/garyburd/go-oauth/oauth"
+)
+
+const (
+	_MaxThreadDepth  = 50
+	_MaxThreadTweets = 200
+)
+
+// threadFetcher fetches tweets of a thread, caching tweets already fetched
+type threadFetcher struct {
+	token  *oauth.Credentials
+	tweets map[string]*Tweet
+}
+
+func newThreadFetcher(token *oauth.Credentials) *threadFetcher {
+	return &threadFetcher{token: token, tweets: map[string]*Tweet{}}
+}
+
+// get returns the tweet of the ID, fetching it if not cached
+func (f *threadFetcher) get(id string) (*Tweet, error) {
+	if tweet, ok := f.tweets[id]; ok {
+		return tweet, nil
+	}
+	var tweet Tweet
+	err := rawCall(f.token, http.MethodGet, "https://api.twitter.com/1.1/statuses/show.json", map[string]string{"id": id}, &tweet)
+	if err != nil {
+		return nil, err
+	}
+	if tweet.Identifier == "" {
+		return nil, fmt.Errorf("tweet %v not found", id)
+	}
+	f.tweets[id] = &tweet
+	return &tweet, nil
+}
+
+// root walks up in_reply_to_status_id from the tweet, and returns the first
+// tweet of the thread which is available.
+func (f *threadFetcher) root(id string) (*Tweet, error) {
+	tweet, err := f.get(id)
+	if err != nil {
+		return nil, err
+	}
+	visited := map[string]bool{id: true}
+	for depth := 0; tweet.InReplyToStatusID != "" && depth < _MaxThreadDepth; depth++ {
+		if visited[tweet.InReplyToStatusID] {
+			break
+		}
+		visited[tweet.InReplyToStatusID] = true
+		parent, err := f.get(tweet.InReplyToStatusID)
+		if err != nil {
+			// the parent may be deleted or protected
+			fmt.Fprintf(os.Stderr, "cannot get tweet %v: %v\n", tweet.InReplyToStatusID, err)
+			break
+		}
+		tweet = parent
+	}
+	return tweet, nil
+}
+
+// replies searches replies to the tweet, which are found only in recent tweets
+func (f *threadFetcher) replies(tweet *Tweet) ([]*Tweet, error) {
+	res := struct {
+		Statuses []Tweet `json:"statuses"`
+	}{}
+	opt := map[string]string{
+		"q":        "to:" + tweet.User.ScreenName,
+		"since_id": tweet.Identifier,
+		"count":    "100",
+	}
+	err := rawCall(f.token, http.MethodGet, "https://api.twitter.com/1.1/search/tweets.json", opt, &res)
+	if err != nil {
+		return nil, err
+	}
+	var replies []*Tweet
+	for i := range res.Statuses {
+		reply := &res.Statuses[i]
+		if cached, ok := f.tweets[reply.Identifier]; ok {
+			reply = cached
+		} else {
+			f.tweets[reply.Identifier] = reply
+		}
+		if reply.InReplyToStatusID == tweet.Identifier {
+			replies = append(replies, reply)
+		}
+	}
+	sort.Slice(replies, func(i, j int) bool {
+		a, _ := strconv.ParseInt(replies[i].Identifier, 10, 64)
+		b, _ := strconv.ParseInt(replies[j].Identifier, 10, 64)
+		return a < b
+	})
+	return replies, nil
+}
+
+// fetchThread returns tweets of the thread containing the tweet, ordered
+// from newest to oldest like timelines. Depth of each tweet is set to show
+// them as a tree.
+func fetchThread(token *oauth.Credentials, id string) ([]Tweet, error) {
+	f := newThreadFetcher(token)
+	root, err := f.root(id)
+	if err != nil {
+		return nil, err
+	}
+	var thread []Tweet
+	visited := map[string]bool{}
+	var walk func(tweet *Tweet, depth int)
+	walk = func(tweet *Tweet, depth int) {
+		if visited[tweet.Identifier] || len(thread) >= _MaxThreadTweets {
+			return
+		}
+		visited[tweet.Identifier] = true
+		t := *tweet
+		t.Depth = depth
+		thread = append(thread, t)
+		if depth >= _MaxThreadDepth {
+			return
+		}
+		replies, err := f.replies(tweet)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot get replies to %v: %v\n", tweet.Identifier, err)
+			return
+		}
+		for _, reply := range replies {
+			walk(reply, depth+1)
+		}
+	}
+	walk(root, 0)
+
+	for i, j := 0, len(thread)-1; i < j; i, j = i+1, j-1 {
+		thread[i], thread[j] = thread[j], thread[i]
+	}
+	return thread, nil
+}
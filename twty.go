@@ -3,22 +3,30 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html"
 	"io"
 	"io/ioutil"
 	"log"
+	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
@@ -26,8 +34,12 @@ import (
 )
 
 const (
-	_EmojiRedHeart    = "\u2764"
-	_EmojiHighVoltage = "\u26A1"
+	_EmojiRedHeart      = "\u2764"
+	_EmojiHighVoltage   = "\u26A1"
+	_EmojiSpeechBalloon = "\U0001F4AC"
+	_EmojiRepeatButton  = "\U0001F501"
+	_EmojiSpeechBubble  = "\U0001F5E8"
+	_EmojiRoundPushpin  = "\U0001F4CD"
 )
 
 // Account hold information about account
@@ -71,20 +83,32 @@ type Account struct {
 
 // Tweet hold information about tweet
 type Tweet struct {
-	Text       string `json:"text"`
+	// Profile is name of the profile which fetched the tweet in merged view
+	Profile string `json:"profile,omitempty"`
+	// Depth is depth of the tweet in the thread view
+	Depth int    `json:"depth,omitempty"`
+	Text  string `json:"text"`
+	// FullText is returned instead of Text with tweet_mode=extended
+	FullText   string `json:"full_text,omitempty"`
 	Identifier string `json:"id_str"`
 	Source     string `json:"source"`
+	Lang       string `json:"lang,omitempty"`
 	CreatedAt  string `json:"created_at"`
-	User       struct {
+	// InReplyToStatusID is empty unless the tweet is a reply
+	InReplyToStatusID   string `json:"in_reply_to_status_id_str"`
+	InReplyToScreenName string `json:"in_reply_to_screen_name"`
+	// ReplyCount and QuoteCount are omitted by some endpoints
+	ReplyCount    *int `json:"reply_count,omitempty"`
+	QuoteCount    *int `json:"quote_count,omitempty"`
+	RetweetCount  int  `json:"retweet_count"`
+	FavoriteCount int  `json:"favorite_count"`
+	User          struct {
 		Name            string `json:"name"`
 		ScreenName      string `json:"screen_name"`
 		FollowersCount  int    `json:"followers_count"`
 		ProfileImageURL string `json:"profile_image_url"`
 	} `json:"user"`
-	Place *struct {
-		ID       string `json:"id"`
-		FullName string `json:"full_name"`
-	} `json:"place"`
+	Place    *Place `json:"place"`
 	Entities struct {
 		HashTags []struct {
 			Indices [2]int `json:"indices"`
@@ -95,10 +119,68 @@ type Tweet struct {
 			ScreenName string `json:"screen_name"`
 		} `json:"user_mentions"`
 		Urls []struct {
-			Indices [2]int `json:"indices"`
-			URL     string `json:"url"`
+			Indices     [2]int `json:"indices"`
+			URL         string `json:"url"`
+			ExpandedURL string `json:"expanded_url"`
+			DisplayURL  string `json:"display_url"`
 		} `json:"urls"`
 	} `json:"entities"`
+	ExtendedEntities struct {
+		Media []Media `json:"media"`
+	} `json:"extended_entities"`
+	RetweetedStatus *Tweet `json:"retweeted_status,omitempty"`
+	QuotedStatus    *Tweet `json:"quoted_status,omitempty"`
+	QuotedStatusID  string `json:"quoted_status_id_str,omitempty"`
+	// InReplyTo is the tweet replied to, which is fetched with -context
+	InReplyTo *Tweet `json:"-"`
+}
+
+// UnmarshalJSON decodes the tweet, and replaces Text with the full text,
+// which is truncated in Text of long tweets and retweets.
+func (t *Tweet) UnmarshalJSON(b []byte) error {
+	type tweet Tweet
+	if err := json.Unmarshal(b, (*tweet)(t)); err != nil {
+		return err
+	}
+	if t.FullText != "" {
+		t.Text = t.FullText
+	}
+	if rt := t.RetweetedStatus; rt != nil && rt.Text != "" {
+		t.Text = "RT @" + rt.User.ScreenName + ": " + rt.Text
+	}
+	return nil
+}
+
+// Media hold information about media attached to tweet
+type Media struct {
+	Identifier    string `json:"id_str"`
+	Type          string `json:"type"`
+	MediaURLHTTPS string `json:"media_url_https"`
+	URL           string `json:"url"`
+	ExpandedURL   string `json:"expanded_url"`
+	ExtAltText    string `json:"ext_alt_text,omitempty"`
+	VideoInfo     *struct {
+		Variants []struct {
+			Bitrate     int    `json:"bitrate"`
+			ContentType string `json:"content_type"`
+			URL         string `json:"url"`
+		} `json:"variants"`
+	} `json:"video_info,omitempty"`
+}
+
+// directURL returns URL of the media file. For videos and GIFs, it is the MP4
+// variant of the highest bitrate.
+func (m Media) directURL() string {
+	if m.VideoInfo == nil {
+		return m.MediaURLHTTPS
+	}
+	u, bitrate := m.MediaURLHTTPS, -1
+	for _, v := range m.VideoInfo.Variants {
+		if v.ContentType == "video/mp4" && v.Bitrate > bitrate {
+			u, bitrate = v.URL, v.Bitrate
+		}
+	}
+	return u
 }
 
 type User struct {
@@ -109,6 +191,79 @@ type User struct {
 	FriendsCount    int    `json:"friends_count"`
 	ProfileImageURL string `json:"profile_image_url"`
 	Description     string `json:"description"`
+	Verified        bool   `json:"verified"`
+	Protected       bool   `json:"protected"`
+	Location        string `json:"location"`
+	// URL is the link of the profile, which is shortened with t.co
+	URL           string `json:"url"`
+	StatusesCount int    `json:"statuses_count"`
+	CreatedAt     string `json:"created_at"`
+	Entities      struct {
+		URL struct {
+			URLs []struct {
+				URL         string `json:"url"`
+				ExpandedURL string `json:"expanded_url"`
+			} `json:"urls"`
+		} `json:"url"`
+	} `json:"entities"`
+	// Status is the latest tweet, which is not available for protected users
+	Status *Tweet `json:"status,omitempty"`
+}
+
+// expandedURL returns the link of the profile expanding t.co
+func (u User) expandedURL() string {
+	for _, e := range u.Entities.URL.URLs {
+		if e.URL == u.URL && e.ExpandedURL != "" {
+			return e.ExpandedURL
+		}
+	}
+	return u.URL
+}
+
+// UsersCursor hold information about a page of cursored users
+type UsersCursor struct {
+	Users         []User `json:"users"`
+	NextCursor    int64  `json:"next_cursor"`
+	NextCursorStr string `json:"next_cursor_str"`
+}
+
+// IDsCursor hold information about a page of cursored IDs
+type IDsCursor struct {
+	IDs           []int64 `json:"ids"`
+	NextCursor    int64   `json:"next_cursor"`
+	NextCursorStr string  `json:"next_cursor_str"`
+}
+
+// Relationship hold information about relationship between two users
+type Relationship struct {
+	Source struct {
+		ID                   int64  `json:"id"`
+		ScreenName           string `json:"screen_name"`
+		Following            bool   `json:"following"`
+		FollowedBy           bool   `json:"followed_by"`
+		FollowingRequested   bool   `json:"following_requested"`
+		NotificationsEnabled bool   `json:"notifications_enabled"`
+		Blocking             bool   `json:"blocking"`
+		BlockedBy            bool   `json:"blocked_by"`
+		Muting               bool   `json:"muting"`
+		CanDM                bool   `json:"can_dm"`
+	} `json:"source"`
+	Target struct {
+		ID         int64  `json:"id"`
+		ScreenName string `json:"screen_name"`
+		Following  bool   `json:"following"`
+		FollowedBy bool   `json:"followed_by"`
+	} `json:"target"`
+}
+
+// Place hold information about place of geotagged tweets
+type Place struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	PlaceType   string `json:"place_type"`
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code"`
 }
 
 // SearchMetadata hold information about search metadata
@@ -152,17 +307,102 @@ func (f *files) Set(value string) error {
 	return nil
 }
 
+type params map[string]string
+
+func (p params) String() string {
+	var s []string
+	for k, v := range p {
+		s = append(s, k+"="+v)
+	}
+	return strings.Join(s, ",")
+}
+
+func (p params) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("invalid parameter %q, should be key=value", value)
+	}
+	p[kv[0]] = kv[1]
+	return nil
+}
+
+const _APIBase = "https://api.twitter.com/"
+
+// rawEndpoint parses METHOD:ENDPOINT and returns method and URL of the API
+func rawEndpoint(raw string) (string, string, error) {
+	part := strings.SplitN(raw, ":", 2)
+	if len(part) != 2 {
+		return "", "", fmt.Errorf("invalid request %q, should be METHOD:ENDPOINT", raw)
+	}
+	method := strings.ToUpper(part[0])
+	if method != http.MethodGet && method != http.MethodPost {
+		return "", "", fmt.Errorf("unsupported method %q", part[0])
+	}
+	uri := part[1]
+	if !strings.HasPrefix(uri, "https://") {
+		uri = _APIBase + strings.TrimLeft(uri, "/")
+	}
+	if !strings.HasPrefix(uri, _APIBase) {
+		return "", "", fmt.Errorf("endpoint %q is not under %s", part[1], _APIBase)
+	}
+	return method, uri, nil
+}
+
 var oauthClient = oauth.Client{
 	TemporaryCredentialRequestURI: "https://api.twitter.com/oauth/request_token",
 	ResourceOwnerAuthorizationURI: "https://api.twitter.com/oauth/authenticate",
 	TokenRequestURI:               "https://api.twitter.com/oauth/access_token",
 }
 
-func clientAuth(requestToken *oauth.Credentials) (*oauth.Credentials, error) {
-	var err error
-	browser := "xdg-open"
-	url := oauthClient.AuthorizationURL(requestToken, nil)
+// prompts hold texts of interactive prompts, which can be overridden with
+// the configuration file. Empty PromptOpenURL suppresses the banner.
+var prompts = map[string]string{
+	"PromptOpenURL":   "Open this URL and enter PIN.",
+	"PromptPIN":       "PIN: ",
+	"PromptAuthorize": "Open this URL and authorize the app.",
+	"PromptCode":      "Paste the URL redirected to: ",
+	"PromptConfirm":   "{question} [y/N]: ",
+}
+
+func loadPrompts(config map[string]string) {
+	for k := range prompts {
+		if v, ok := config[k]; ok {
+			prompts[k] = v
+		}
+	}
+}
+
+// copyToClipboard puts the text on the system clipboard
+func copyToClipboard(text string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("clip")
+	case "darwin":
+		cmd = exec.Command("pbcopy")
+	default:
+		for _, args := range [][]string{
+			{"wl-copy"},
+			{"xclip", "-selection", "clipboard"},
+			{"xsel", "--clipboard", "--input"},
+		} {
+			if _, err := exec.LookPath(args[0]); err == nil {
+				cmd = exec.Command(args[0], args[1:]...)
+				break
+			}
+		}
+		if cmd == nil {
+			return fmt.Errorf("no clipboard command found (wl-copy, xclip or xsel)")
+		}
+	}
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
 
+// openURL shows the URL with the banner, and opens it with the browser
+func openURL(url string, banner string) error {
+	browser := "xdg-open"
 	args := []string{url}
 	if runtime.GOOS == "windows" {
 		browser = "rundll32.exe"
@@ -173,33 +413,48 @@ func clientAuth(requestToken *oauth.Credentials) (*oauth.Credentials, error) {
 	} else if runtime.GOOS == "plan9" {
 		browser = "plumb"
 	}
-	color.Set(color.FgHiRed)
-	fmt.Println("Open this URL and enter PIN.")
-	color.Set(color.Reset)
+	if banner != "" {
+		color.Set(color.FgHiRed)
+		fmt.Println(banner)
+		color.Set(color.Reset)
+	}
 	fmt.Println(url)
-	browser, err = exec.LookPath(browser)
+	browser, err := exec.LookPath(browser)
 	if err == nil {
 		cmd := exec.Command(browser, args...)
 		cmd.Stderr = os.Stderr
 		err = cmd.Start()
 		if err != nil {
-			return nil, fmt.Errorf("cannot start command: %v", err)
+			return fmt.Errorf("cannot start command: %v", err)
 		}
 	}
+	return nil
+}
+
+// clientAuth authorizes with PIN, and returns access token and screen name
+func clientAuth(requestToken *oauth.Credentials) (*oauth.Credentials, string, error) {
+	err := openURL(oauthClient.AuthorizationURL(requestToken, nil), prompts["PromptOpenURL"])
+	if err != nil {
+		return nil, "", err
+	}
 
-	fmt.Print("PIN: ")
+	fmt.Print(prompts["PromptPIN"])
 	stdin := bufio.NewScanner(os.Stdin)
 	if !stdin.Scan() {
-		return nil, fmt.Errorf("canceled")
+		return nil, "", fmt.Errorf("canceled")
 	}
-	accessToken, _, err := oauthClient.RequestToken(http.DefaultClient, requestToken, stdin.Text())
+	accessToken, values, err := oauthClient.RequestToken(http.DefaultClient, requestToken, stdin.Text())
 	if err != nil {
-		return nil, fmt.Errorf("cannot request token: %v", err)
+		return nil, "", fmt.Errorf("cannot request token: %v", err)
 	}
-	return accessToken, nil
+	return accessToken, values.Get("screen_name"), nil
 }
 
-func getAccessToken(config map[string]string) (*oauth.Credentials, bool, error) {
+// getAccessToken returns access token of OAuth 1.0a, authorizing with PIN if
+// it is not in config. If oauth2 is true, it also gets access token of OAuth
+// 2.0 into config["OAuth2AccessToken"]. The second value is true when config
+// is updated.
+func getAccessToken(config map[string]string, oauth2 bool) (*oauth.Credentials, bool, error) {
 	oauthClient.Credentials.Token = config["ClientToken"]
 	oauthClient.Credentials.Secret = config["ClientSecret"]
 
@@ -215,7 +470,8 @@ func getAccessToken(config map[string]string) (*oauth.Credentials, bool, error)
 			err = fmt.Errorf("cannot request temporary credentials: %v", err)
 			return nil, false, err
 		}
-		token, err = clientAuth(requestToken)
+		var screenName string
+		token, screenName, err = clientAuth(requestToken)
 		if err != nil {
 			err = fmt.Errorf("cannot request temporary credentials: %v", err)
 			return nil, false, err
@@ -223,18 +479,312 @@ func getAccessToken(config map[string]string) (*oauth.Credentials, bool, error)
 
 		config["AccessToken"] = token.Token
 		config["AccessSecret"] = token.Secret
+		if screenName != "" {
+			config["ScreenName"] = screenName
+		}
 		authorized = true
 	}
+	if oauth2 {
+		_, updated, err := getOAuth2Token(config)
+		if err != nil {
+			return nil, false, fmt.Errorf("cannot get access token of OAuth 2.0: %v", err)
+		}
+		authorized = authorized || updated
+	}
 	return token, authorized, nil
 }
 
+// reportElapsed prints the duration of the API call to stderr
+func reportElapsed(method string, uri string, start time.Time) {
+	if showElapsed {
+		fmt.Fprintf(os.Stderr, "%s %s (%dms)\n", method, uri, time.Since(start).Milliseconds())
+	}
+}
+
+// circuitBreaker pauses API calls for cooloff after maxFailures consecutive
+// failures, and aborts when the failures continue after the cooloff. It is
+// safe for concurrent uploads of media.
+type circuitBreaker struct {
+	mu          sync.Mutex
+	maxFailures int
+	cooloff     time.Duration
+	failures    int
+	cooled      bool
+}
+
+var breaker = circuitBreaker{maxFailures: 5, cooloff: time.Minute}
+
+// wait waits cooloff if needed before the next call
+func (b *circuitBreaker) wait(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxFailures <= 0 || b.failures < b.maxFailures {
+		return nil
+	}
+	if b.cooled {
+		return fmt.Errorf("giving up after %d consecutive failures", b.failures)
+	}
+	fmt.Fprintf(os.Stderr, "%d consecutive failures, cooling off for %v\n", b.failures, b.cooloff)
+	select {
+	case <-time.After(b.cooloff):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	b.failures = 0
+	b.cooled = true
+	return nil
+}
+
+func (b *circuitBreaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ok {
+		b.failures = 0
+		b.cooled = false
+	} else {
+		b.failures++
+	}
+}
+
+var (
+	// requestContext bounds total time of all API calls with -deadline
+	requestContext = context.Background()
+	// requestTimeout bounds time of each API call with -timeout-per-try
+	requestTimeout time.Duration
+)
+
+var errDeadlineExceeded = errors.New("deadline exceeded")
+
+var (
+	// dryRunWrites shows requests changing anything without sending them
+	// with -dry-run
+	dryRunWrites bool
+	// confirmWrites asks confirmation before sending requests changing
+	// anything with -confirm
+	confirmWrites bool
+)
+
+// readOnlyPOSTs hold endpoints called with POST which do not change anything
+var readOnlyPOSTs = map[string]bool{
+	"https://api.twitter.com/1.1/users/lookup.json": true,
+}
+
+var (
+	errCanceled = errors.New("canceled")
+	// errSkipped is returned instead of sending requests with -dry-run.
+	// Callers treat it as success, so that all requests of the command are
+	// shown.
+	errSkipped = errors.New("skipped by dry run")
+)
+
+// guardWrite shows the request changing anything with -dry-run or -confirm.
+// It returns errSkipped without sending the request with -dry-run, and
+// errCanceled if the request is not confirmed with -confirm. Uploads of media
+// are not shown because uploadMedia does not upload with -dry-run.
+func guardWrite(method string, uri string, param url.Values, body interface{}) error {
+	if !dryRunWrites && !confirmWrites {
+		return nil
+	}
+	if method == http.MethodGet || readOnlyPOSTs[uri] || strings.HasPrefix(uri, _UploadURL) {
+		return nil
+	}
+	fmt.Println(method, uri)
+	keys := make([]string, 0, len(param))
+	for k := range param {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  %s=%s\n", k, param.Get(k))
+	}
+	if body != nil {
+		b, err := json.MarshalIndent(body, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  %s\n", b)
+	}
+	if dryRunWrites {
+		fmt.Println("dry run: the request is not sent")
+		return errSkipped
+	}
+	if !confirm("Send this request?") {
+		return errCanceled
+	}
+	return nil
+}
+
+// doRequest sends the request to the API
+func doRequest(req *http.Request) (*http.Response, error) {
+	if err := breaker.wait(requestContext); err != nil {
+		if requestContext.Err() == context.DeadlineExceeded {
+			return nil, errDeadlineExceeded
+		}
+		return nil, err
+	}
+	if requestContext.Err() == context.DeadlineExceeded {
+		return nil, errDeadlineExceeded
+	}
+	client := &http.Client{Timeout: requestTimeout}
+	start := time.Now()
+	resp, err := client.Do(req.WithContext(requestContext))
+	reportElapsed(req.Method, req.URL.Scheme+"://"+req.URL.Host+req.URL.Path, start)
+	breaker.record(err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500)
+	if err != nil {
+		if requestContext.Err() == context.DeadlineExceeded {
+			return nil, errDeadlineExceeded
+		}
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			return nil, fmt.Errorf("timeout after %v: %v", requestTimeout, err)
+		}
+	}
+	return resp, err
+}
+
+const (
+	// _ErrorNoUserMatches is the error code of API v1.1 when none of users
+	// are found
+	_ErrorNoUserMatches = 17
+	// _ErrorDuplicate is the error code of API v1.1 for duplicate tweets
+	_ErrorDuplicate = 187
+)
+
+// APIError hold information about error returned from API
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"-"`
+	// Reset is the time when the rate limit is reset, if the request is
+	// rate limited
+	Reset time.Time `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code == _ErrorDuplicate {
+		return "duplicate tweet: twitter rejects the same text as a recent tweet (code 187)"
+	}
+	if e.Code != 0 {
+		return fmt.Sprintf("%s: %s (code %d)", e.Status, e.Message, e.Code)
+	}
+	return e.Status + ": " + e.Message
+}
+
+// apiError returns the error in the body of the error response of API v1.1,
+// v2 or OAuth 2.0, or nil if the body has no error
+func apiError(resp *http.Response, b []byte) error {
+	var res struct {
+		Errors []APIError `json:"errors"`
+		// errors of API v2
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+		// errors of OAuth 2.0
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if json.Unmarshal(b, &res) != nil {
+		return nil
+	}
+	if len(res.Errors) > 0 && res.Errors[0].Message != "" {
+		e := res.Errors[0]
+		e.Status = resp.Status
+		e.Reset = rateLimitReset(resp)
+		return &e
+	}
+	if res.Detail != "" {
+		e := &APIError{Message: res.Detail, Status: resp.Status, Reset: rateLimitReset(resp)}
+		if strings.Contains(res.Detail, "duplicate content") {
+			e.Code = _ErrorDuplicate
+		}
+		return e
+	}
+	if res.Error != "" {
+		return &APIError{Message: res.Error + ": " + res.ErrorDescription, Status: resp.Status}
+	}
+	return nil
+}
+
+// rateLimitReset returns the time when the rate limit is reset if the
+// response is rate limited, or zero time
+func rateLimitReset(resp *http.Response) time.Time {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("x-rate-limit-reset"), 10, 64)
+	if err != nil {
+		// wait for the window of 15 minutes
+		return time.Now().Add(15 * time.Minute)
+	}
+	return time.Unix(reset, 0)
+}
+
+// waitRateLimit sleeps until the rate limit is reset if err is of the rate
+// limit, and returns true to retry. It returns false if err is another
+// error, or the deadline comes before the reset.
+func waitRateLimit(err error) bool {
+	e, ok := err.(*APIError)
+	if !ok || e.Reset.IsZero() {
+		return false
+	}
+	// a second of margin for difference of clocks
+	reset := e.Reset.Add(time.Second)
+	if deadline, ok := requestContext.Deadline(); ok && deadline.Before(reset) {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "rate limited: waiting until %s\n", reset.Format("15:04:05"))
+	select {
+	case <-time.After(time.Until(reset)):
+		return true
+	case <-requestContext.Done():
+		return false
+	}
+}
+
+// decodeResponse decodes JSON of the response. If the response is not JSON
+// (ex: error page of a proxy), it returns an error with a part of the body.
+// If res is nil, it only checks the status of the response.
+func decodeResponse(resp *http.Response, res interface{}) error {
+	if res == nil && resp.StatusCode/100 == 2 {
+		return nil
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil || !strings.HasSuffix(mt, "json") {
+			b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 200))
+			return fmt.Errorf("expected JSON, got %s (%s): %q", ct, resp.Status, b)
+		}
+	}
+	if resp.StatusCode/100 != 2 {
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if debug {
+			os.Stdout.Write(b)
+		}
+		if err = apiError(resp, b); err != nil {
+			return err
+		}
+		if len(b) > 200 {
+			b = b[:200]
+		}
+		return fmt.Errorf("%s: %q", resp.Status, b)
+	}
+	if debug {
+		return json.NewDecoder(io.TeeReader(resp.Body, os.Stdout)).Decode(&res)
+	}
+	return json.NewDecoder(resp.Body).Decode(&res)
+}
+
 func upload(token *oauth.Credentials, file string, opt map[string]string, res interface{}) error {
 	uri := "https://upload.twitter.com/1.1/media/upload.json"
 	param := make(url.Values)
 	for k, v := range opt {
 		param.Set(k, v)
 	}
-	oauthClient.SignParam(token, http.MethodPost, uri, param)
+	// parameters are sent in the query, as the multipart body is not signed
+	if len(param) > 0 {
+		uri += "?" + param.Encode()
+	}
 	var buf bytes.Buffer
 
 	w := multipart.NewWriter(&buf)
@@ -253,22 +803,24 @@ func upload(token *oauth.Credentials, file string, opt map[string]string, res in
 	}
 	w.Close()
 
-	req, err := http.NewRequest(http.MethodPost, uri, &buf)
+	progress := newUploadProgress(filepath.Base(file), int64(buf.Len()))
+	defer progress.done()
+	req, err := http.NewRequest(http.MethodPost, uri, progress.reader(&buf))
 	if err != nil {
 		return err
 	}
+	req.ContentLength = int64(buf.Len())
 	req.Header.Set("Content-Type", w.FormDataContentType())
-	req.Header.Set("Authorization", "OAuth "+strings.Replace(param.Encode(), "&", ",", -1))
+	if err = oauthClient.SetAuthorizationHeader(req.Header, token, http.MethodPost, req.URL, nil); err != nil {
+		return err
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	if res == nil {
-		return nil
-	}
-	return json.NewDecoder(resp.Body).Decode(&res)
+	return decodeResponse(resp, res)
 }
 
 func rawCall(token *oauth.Credentials, method string, uri string, opt map[string]string, res interface{}) error {
@@ -276,87 +828,649 @@ func rawCall(token *oauth.Credentials, method string, uri string, opt map[string
 	for k, v := range opt {
 		param.Set(k, v)
 	}
+	if method == http.MethodGet && strings.HasPrefix(uri, "https://api.twitter.com/1.1/") && param.Get("tweet_mode") == "" {
+		// fetch full text of tweets longer than 140 characters, and alt text
+		// of images
+		param.Set("tweet_mode", "extended")
+		param.Set("include_ext_alt_text", "true")
+	}
+	if err := guardWrite(method, uri, param, nil); err != nil {
+		return err
+	}
 	oauthClient.SignParam(token, method, uri, param)
-	var resp *http.Response
+	var req *http.Request
 	var err error
 	if method == http.MethodGet {
-		uri = uri + "?" + param.Encode()
-		resp, err = http.Get(uri)
+		req, err = http.NewRequest(method, uri+"?"+param.Encode(), nil)
 	} else {
-		resp, err = http.PostForm(uri, url.Values(param))
+		req, err = http.NewRequest(method, uri, strings.NewReader(param.Encode()))
+	}
+	if err != nil {
+		return err
 	}
+	if method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := doRequest(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	if res == nil {
-		return nil
+	return decodeResponse(resp, res)
+}
+
+func bearerCall(bearer string, uri string, opt map[string]string, res interface{}) error {
+	param := make(url.Values)
+	for k, v := range opt {
+		param.Set(k, v)
 	}
-	if debug {
-		return json.NewDecoder(io.TeeReader(resp.Body, os.Stdout)).Decode(&res)
+	req, err := http.NewRequest(http.MethodGet, uri+"?"+param.Encode(), nil)
+	if err != nil {
+		return err
 	}
-	return json.NewDecoder(resp.Body).Decode(&res)
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	resp, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, res)
 }
 
-var replacer = strings.NewReplacer(
-	"\r", "",
-	"\n", " ",
-	"\t", " ",
-)
+// screenNames splits values of flags like -follow separated by commas, and
+// trims "@" of the screen names. "-" reads screen names separated by lines,
+// spaces or commas from STDIN.
+func screenNames(values []string) ([]string, error) {
+	var names []string
+	for _, value := range values {
+		if value == "-" {
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, err
+			}
+			value = strings.Join(strings.Fields(string(b)), ",")
+		}
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
 
-const _TimeLayout = "Mon Jan 02 15:04:05 -0700 2006"
+// cursorUsers fetches users following the cursor until the end or limit.
+// It sleeps until the rate limit is reset if it is hit on the way.
+func cursorUsers(token *oauth.Credentials, uri string, opt map[string]string, limit int) ([]User, error) {
+	var users []User
+	cursor := "-1"
+	for cursor != "" && cursor != "0" {
+		opt["cursor"] = cursor
+		var res UsersCursor
+		err := rawCall(token, http.MethodGet, uri, opt, &res)
+		if err != nil && waitRateLimit(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, res.Users...)
+		if limit > 0 && len(users) >= limit {
+			return users[:limit], nil
+		}
+		cursor = res.NextCursorStr
+	}
+	return users, nil
+}
 
-func toLocalTime(timeStr string) string {
-	timeValue, err := time.Parse(_TimeLayout, timeStr)
-	if err != nil {
-		return timeStr
+// cursorIDs fetches IDs following the cursor until the end or limit.
+// It sleeps until the rate limit is reset if it is hit on the way.
+func cursorIDs(token *oauth.Credentials, uri string, opt map[string]string, limit int) ([]int64, error) {
+	var ids []int64
+	cursor := "-1"
+	for cursor != "" && cursor != "0" {
+		opt["cursor"] = cursor
+		var res IDsCursor
+		err := rawCall(token, http.MethodGet, uri, opt, &res)
+		if err != nil && waitRateLimit(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, res.IDs...)
+		if limit > 0 && len(ids) >= limit {
+			return ids[:limit], nil
+		}
+		cursor = res.NextCursorStr
 	}
-	return timeValue.Local().Format(_TimeLayout)
+	return ids, nil
 }
 
-func showTweets(tweets []Tweet, asjson bool, verbose bool) {
-	if asjson {
-		for _, tweet := range tweets {
-			json.NewEncoder(os.Stdout).Encode(tweet)
-			os.Stdout.Sync()
+// lookupUsers fetches users of the IDs, 100 users at once
+func lookupUsers(token *oauth.Credentials, ids []int64) ([]User, error) {
+	var users []User
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > 100 {
+			n = 100
 		}
-	} else if verbose {
-		for i := len(tweets) - 1; i >= 0; i-- {
-			name := tweets[i].User.Name
-			user := tweets[i].User.ScreenName
-			text := tweets[i].Text
-			text = replacer.Replace(text)
-			color.Set(color.FgHiRed)
-			fmt.Println(user + ": " + name)
-			color.Set(color.Reset)
-			fmt.Println("  " + html.UnescapeString(text))
-			fmt.Println("  " + tweets[i].Identifier)
-			fmt.Println("  " + toLocalTime(tweets[i].CreatedAt))
-			fmt.Println()
+		s := make([]string, n)
+		for i, id := range ids[:n] {
+			s[i] = strconv.FormatInt(id, 10)
 		}
-	} else {
-		for i := len(tweets) - 1; i >= 0; i-- {
-			user := tweets[i].User.ScreenName
-			text := tweets[i].Text
-			color.Set(color.FgHiRed)
-			fmt.Print(user)
-			color.Set(color.Reset)
-			fmt.Print(": ")
-			fmt.Println(html.UnescapeString(text))
+		var res []User
+		err := rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/users/lookup.json", map[string]string{"user_id": strings.Join(s, ",")}, &res)
+		if err != nil {
+			return nil, err
 		}
+		users = append(users, res...)
+		ids = ids[n:]
 	}
+	return users, nil
 }
 
-func showUser(user User, asjson bool, verbose bool) {
-	if asjson {
-		json.NewEncoder(os.Stdout).Encode(user)
-		os.Stdout.Sync()
-	} else if verbose {
+// lookupUserList fetches users of screen names or IDs prefixed with "id:",
+// 100 users at once. Users not found are not returned.
+func lookupUserList(token *oauth.Credentials, names []string) ([]User, error) {
+	var users []User
+	for len(names) > 0 {
+		n := len(names)
+		if n > 100 {
+			n = 100
+		}
+		var screenNames, ids []string
+		for _, name := range names[:n] {
+			if strings.HasPrefix(name, "id:") {
+				ids = append(ids, strings.TrimPrefix(name, "id:"))
+			} else {
+				screenNames = append(screenNames, name)
+			}
+		}
+		opt := map[string]string{}
+		if len(screenNames) > 0 {
+			opt["screen_name"] = strings.Join(screenNames, ",")
+		}
+		if len(ids) > 0 {
+			opt["user_id"] = strings.Join(ids, ",")
+		}
+		var res []User
+		err := rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/users/lookup.json", opt, &res)
+		// users/lookup returns 404 if none of the users are found
+		if e, ok := err.(*APIError); ok && e.Code == _ErrorNoUserMatches {
+			err = nil
+		}
+		if err != nil && waitRateLimit(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, res...)
+		names = names[n:]
+	}
+	return users, nil
+}
+
+// _FollowInterval is interval between follows to avoid hitting rate limits
+const _FollowInterval = time.Second
+
+// userAction hold information about an API to act on users, like follow or block
+type userAction struct {
+	// name is the name of the flag without "-", like "follow"
+	name string
+	uri  string
+	// done is shown with screen names of users acted on, like "followed"
+	done  string
+	users files
+	// opt is additional parameters of the API
+	opt map[string]string
+}
+
+// actOnUsers calls the API of the action for each screen name, or user ID
+// prefixed with "id:", waiting for interval between calls. f is called with
+// the user after each success if it is not nil. It returns errors of the
+// users, which are nil for successes. Failures are reported to STDERR.
+func actOnUsers(token *oauth.Credentials, action userAction, names []string, interval time.Duration, f func(User)) []error {
+	errs := make([]error, len(names))
+	failed := 0
+	for i, name := range names {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		opt := map[string]string{"screen_name": name}
+		if strings.HasPrefix(name, "id:") {
+			opt = map[string]string{"user_id": strings.TrimPrefix(name, "id:")}
+		}
+		for k, v := range action.opt {
+			opt[k] = v
+		}
+		var user User
+		err := rawCall(token, http.MethodPost, action.uri, opt, &user)
+		for err != nil && waitRateLimit(err) {
+			err = rawCall(token, http.MethodPost, action.uri, opt, &user)
+		}
+		if err == errSkipped {
+			continue
+		}
+		if err == nil && user.ScreenName == "" {
+			err = errors.New("user not found")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot %s %s: %v\n", action.name, name, err)
+			errs[i] = err
+			failed++
+			continue
+		}
+		fmt.Println(action.done+":", user.ScreenName)
+		if f != nil {
+			f(user)
+		}
+	}
+	if len(names) > 1 && !dryRunWrites {
+		fmt.Printf("%s %d of %d users\n", action.done, len(names)-failed, len(names))
+	}
+	return errs
+}
+
+// readUserList reads screen names or user IDs of the file, one per line.
+// Empty lines and lines starting with "#" are ignored. IDs are returned
+// prefixed with "id:". Screen names of only digits must be prefixed with "@".
+func readUserList(file string) ([]string, error) {
+	b, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseUserList(strings.Split(string(b), "\n")), nil
+}
+
+// parseUserList parses lines of readUserList
+func parseUserList(lines []string) []string {
+	var names []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// ignore anything after the user, like results written with -result
+		line = strings.Fields(line)[0]
+		if _, err := strconv.ParseInt(line, 10, 64); err == nil {
+			line = "id:" + line
+		}
+		names = append(names, strings.TrimPrefix(line, "@"))
+	}
+	return names
+}
+
+// writeUserResults writes results of actOnUsers to the file, a line of the
+// user and the result (or error) for each user
+func writeUserResults(file string, action userAction, names []string, errs []error) error {
+	var buf bytes.Buffer
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	fmt.Fprintf(&buf, "# %s %d of %d users at %s\n", action.done, len(names)-failed, len(names), time.Now().Format(time.RFC3339))
+	for i, name := range names {
+		// screen names are prefixed with "@" not to be read as IDs
+		if strings.HasPrefix(name, "id:") {
+			name = strings.TrimPrefix(name, "id:")
+		} else {
+			name = "@" + name
+		}
+		if errs[i] != nil {
+			fmt.Fprintf(&buf, "%s\terror: %v\n", name, errs[i])
+		} else {
+			fmt.Fprintf(&buf, "%s\t%s\n", name, action.done)
+		}
+	}
+	return ioutil.WriteFile(file, buf.Bytes(), 0600)
+}
+
+// notFollowedBack returns followers who are not in friends
+func notFollowedBack(followers []User, friends []User) []User {
+	following := make(map[int]bool)
+	for _, friend := range friends {
+		following[friend.Id] = true
+	}
+	var users []User
+	for _, follower := range followers {
+		if !following[follower.Id] {
+			users = append(users, follower)
+		}
+	}
+	return users
+}
+
+var replacer = strings.NewReplacer(
+	"\r", "",
+	"\n", " ",
+	"\t", " ",
+)
+
+const _TimeLayout = "Mon Jan 02 15:04:05 -0700 2006"
+
+func toLocalTime(timeStr string) string {
+	timeValue, err := time.Parse(_TimeLayout, timeStr)
+	if err != nil {
+		return timeStr
+	}
+	return timeValue.Local().Format(_TimeLayout)
+}
+
+// engagementCounts returns compact line of reply/retweet/quote/favorite counts
+func engagementCounts(tweet Tweet) string {
+	var counts []string
+	if tweet.ReplyCount != nil {
+		counts = append(counts, _EmojiSpeechBalloon+strconv.Itoa(*tweet.ReplyCount))
+	}
+	counts = append(counts, _EmojiRepeatButton+strconv.Itoa(tweet.RetweetCount))
+	if tweet.QuoteCount != nil {
+		counts = append(counts, _EmojiSpeechBubble+strconv.Itoa(*tweet.QuoteCount))
+	}
+	counts = append(counts, _EmojiRedHeart+strconv.Itoa(tweet.FavoriteCount))
+	return strings.Join(counts, " ")
+}
+
+func showTweets(tweets []Tweet, asjson bool, verbose bool) {
+	if asjson {
+		for _, tweet := range tweets {
+			json.NewEncoder(os.Stdout).Encode(tweet)
+			os.Stdout.Sync()
+		}
+	} else if verbose {
+		for i := len(tweets) - 1; i >= 0; i-- {
+			name := tweets[i].User.Name
+			user := tweets[i].User.ScreenName
+			text := tweets[i].Text
+			text = replacer.Replace(text)
+			indent := strings.Repeat("  ", tweets[i].Depth)
+			showReplyContext(tweets[i], indent)
+			fmt.Print(indent)
+			if tweets[i].Profile != "" {
+				fmt.Print("[" + tweets[i].Profile + "] ")
+			}
+			color.Set(color.FgHiRed)
+			fmt.Println(user + ": " + name)
+			color.Set(color.Reset)
+			fmt.Println(indent + "  " + html.UnescapeString(text))
+			if q := quotedTweet(tweets[i]); q != nil {
+				fmt.Println(indent + "  > @" + q.User.ScreenName + ": " + html.UnescapeString(replacer.Replace(q.Text)))
+			}
+			showMedia(tweets[i], indent)
+			fmt.Println(indent + "  " + tweets[i].Identifier)
+			fmt.Println(indent + "  " + toLocalTime(tweets[i].CreatedAt))
+			if place := tweets[i].Place; place != nil && place.FullName != "" {
+				fmt.Println(indent + "  " + _EmojiRoundPushpin + " " + place.FullName)
+			}
+			if rt := tweets[i].RetweetedStatus; rt != nil {
+				// counts of the retweet itself are not meaningful
+				fmt.Println(indent + "  " + engagementCounts(*rt))
+			} else {
+				fmt.Println(indent + "  " + engagementCounts(tweets[i]))
+			}
+			fmt.Println()
+		}
+	} else {
+		for i := len(tweets) - 1; i >= 0; i-- {
+			user := tweets[i].User.ScreenName
+			text := tweets[i].Text
+			showReplyContext(tweets[i], strings.Repeat("  ", tweets[i].Depth))
+			fmt.Print(strings.Repeat("  ", tweets[i].Depth))
+			if tweets[i].Profile != "" {
+				fmt.Print("[" + tweets[i].Profile + "] ")
+			}
+			color.Set(color.FgHiRed)
+			fmt.Print(user)
+			color.Set(color.Reset)
+			fmt.Print(": ")
+			fmt.Println(html.UnescapeString(text))
+			if q := quotedTweet(tweets[i]); q != nil {
+				fmt.Println(strings.Repeat("  ", tweets[i].Depth) + "  > @" + q.User.ScreenName + ": " + html.UnescapeString(replacer.Replace(q.Text)))
+			}
+			showMedia(tweets[i], strings.Repeat("  ", tweets[i].Depth))
+		}
+	}
+}
+
+// fetchReplyContext sets InReplyTo of the replies. Tweets replied to are
+// fetched with get unless they are in the tweets.
+func fetchReplyContext(tweets []Tweet, get func(string) (Tweet, error)) {
+	cache := make(map[string]*Tweet)
+	for i := range tweets {
+		cache[tweets[i].Identifier] = &tweets[i]
+	}
+	for i := range tweets {
+		id := tweets[i].InReplyToStatusID
+		if id == "" {
+			continue
+		}
+		parent, ok := cache[id]
+		if !ok {
+			tweet, err := get(id)
+			if err != nil {
+				// the tweet may be deleted or protected
+				fmt.Fprintf(os.Stderr, "cannot get tweet %v: %v\n", id, err)
+			} else {
+				parent = &tweet
+			}
+			cache[id] = parent
+		}
+		if parent != nil {
+			p := *parent
+			p.InReplyTo = nil
+			tweets[i].InReplyTo = &p
+		}
+	}
+}
+
+// quotedTweet returns the tweet quoted by the tweet, or by the retweeted tweet
+func quotedTweet(tweet Tweet) *Tweet {
+	if tweet.QuotedStatus != nil {
+		return tweet.QuotedStatus
+	}
+	if tweet.RetweetedStatus != nil {
+		return tweet.RetweetedStatus.QuotedStatus
+	}
+	return nil
+}
+
+// showMedia prints type and direct URL of media attached to the tweet
+func showMedia(tweet Tweet, indent string) {
+	for _, m := range tweet.ExtendedEntities.Media {
+		fmt.Println(indent + "  [" + m.Type + "] " + m.directURL())
+		if m.ExtAltText != "" {
+			fmt.Println(indent + "    alt: " + replacer.Replace(m.ExtAltText))
+		}
+	}
+}
+
+// showReplyContext prints the tweet replied to dimmed
+func showReplyContext(tweet Tweet, indent string) {
+	if tweet.InReplyTo == nil {
+		return
+	}
+	color.Set(color.FgHiBlack)
+	fmt.Println(indent + "| @" + tweet.InReplyTo.User.ScreenName + ": " + html.UnescapeString(replacer.Replace(tweet.InReplyTo.Text)))
+	color.Set(color.Reset)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"`", "\\`",
+	"*", "\\*",
+	"_", "\\_",
+	"[", "\\[",
+	"]", "\\]",
+	"<", "\\<",
+	">", "\\>",
+	"#", "\\#",
+	"|", "\\|",
+	"~", "\\~",
+)
+
+// markdownText escapes the text as Markdown, and links mentions of the
+// screen names to their profiles
+func markdownText(text string, mentions map[string]bool) string {
+	var buf strings.Builder
+	for {
+		loc := mentionPattern.FindStringSubmatchIndex(text)
+		if loc == nil {
+			break
+		}
+		name := text[loc[2]:loc[3]]
+		if !mentions[strings.ToLower(name)] {
+			buf.WriteString(markdownEscaper.Replace(text[:loc[1]]))
+		} else {
+			buf.WriteString(markdownEscaper.Replace(text[:loc[0]]))
+			buf.WriteString("[@" + markdownEscaper.Replace(name) + "](https://twitter.com/" + name + ")")
+		}
+		text = text[loc[1]:]
+	}
+	buf.WriteString(markdownEscaper.Replace(text))
+	return buf.String()
+}
+
+// tweetMarkdown returns the tweet rendered as Markdown
+func tweetMarkdown(tweet Tweet) string {
+	text := html.UnescapeString(tweet.Text)
+	mentions := make(map[string]bool)
+	for _, m := range tweet.Entities.UserMentions {
+		mentions[strings.ToLower(m.ScreenName)] = true
+	}
+	var body strings.Builder
+	for _, u := range tweet.Entities.Urls {
+		i := strings.Index(text, u.URL)
+		if i < 0 || u.URL == "" {
+			continue
+		}
+		display, expanded := u.DisplayURL, u.ExpandedURL
+		if display == "" {
+			display = u.URL
+		}
+		if expanded == "" {
+			expanded = u.URL
+		}
+		body.WriteString(markdownText(text[:i], mentions))
+		body.WriteString("[" + markdownEscaper.Replace(display) + "](" + expanded + ")")
+		text = text[i+len(u.URL):]
+	}
+	body.WriteString(markdownText(text, mentions))
+
+	var buf strings.Builder
+	buf.WriteString("**@" + tweet.User.ScreenName + "**")
+	if tweet.User.Name != "" {
+		buf.WriteString(" (" + markdownEscaper.Replace(tweet.User.Name) + ")")
+	}
+	buf.WriteString("\n\n")
+	for _, line := range strings.Split(strings.Replace(body.String(), "\r", "", -1), "\n") {
+		buf.WriteString("> " + line + "\n")
+	}
+	if q := quotedTweet(tweet); q != nil {
+		buf.WriteString(">\n> > **@" + q.User.ScreenName + "**\n")
+		for _, line := range strings.Split(strings.Replace(markdownEscaper.Replace(html.UnescapeString(q.Text)), "\r", "", -1), "\n") {
+			buf.WriteString("> > " + line + "\n")
+		}
+	}
+	buf.WriteString("\n[" + toLocalTime(tweet.CreatedAt) + "](" + tweetURL(tweet) + ")\n")
+	return buf.String()
+}
+
+// showEntities prints entities of the tweets for debugging
+func showEntities(tweets []Tweet) {
+	for i := len(tweets) - 1; i >= 0; i-- {
+		tweet := tweets[i]
+		fmt.Println(tweet.Identifier + " @" + tweet.User.ScreenName)
+		showTweetEntities(tweet)
+		fmt.Println()
+	}
+}
+
+// showTweetEntities prints hashtags, mentions, URLs and media of the tweet
+func showTweetEntities(tweet Tweet) {
+	if len(tweet.Entities.HashTags) > 0 {
+		fmt.Println("  hashtags:")
+		for _, h := range tweet.Entities.HashTags {
+			fmt.Printf("    %v #%s\n", h.Indices, h.Text)
+		}
+	}
+	if len(tweet.Entities.UserMentions) > 0 {
+		fmt.Println("  mentions:")
+		for _, m := range tweet.Entities.UserMentions {
+			fmt.Printf("    %v @%s\n", m.Indices, m.ScreenName)
+		}
+	}
+	if len(tweet.Entities.Urls) > 0 {
+		fmt.Println("  urls:")
+		for _, u := range tweet.Entities.Urls {
+			fmt.Printf("    %v %s -> %s (%s)\n", u.Indices, u.URL, u.ExpandedURL, u.DisplayURL)
+		}
+	}
+	if len(tweet.ExtendedEntities.Media) > 0 {
+		fmt.Println("  media:")
+		for _, m := range tweet.ExtendedEntities.Media {
+			fmt.Printf("    %s %s -> %s\n", m.Type, m.URL, m.MediaURLHTTPS)
+			if m.ExtAltText != "" {
+				fmt.Printf("      alt: %s\n", m.ExtAltText)
+			}
+			if m.VideoInfo != nil {
+				for _, v := range m.VideoInfo.Variants {
+					fmt.Printf("      %s %d %s\n", v.ContentType, v.Bitrate, v.URL)
+				}
+			}
+		}
+	}
+}
+
+// showStatus prints the tweet in detail with its entities
+func showStatus(tweet Tweet, asjson bool) {
+	if asjson {
+		showTweets([]Tweet{tweet}, true, false)
+		return
+	}
+	showTweets([]Tweet{tweet}, false, true)
+	showTweetEntities(tweet)
+}
+
+func showMarkdown(tweets []Tweet) {
+	for i := len(tweets) - 1; i >= 0; i-- {
+		fmt.Println(tweetMarkdown(tweets[i]))
+	}
+}
+
+func showUser(user User, asjson bool, verbose bool) {
+	if asjson {
+		json.NewEncoder(os.Stdout).Encode(user)
+		os.Stdout.Sync()
+	} else if verbose {
 		fmt.Printf("id: %d\n", user.Id)
 		fmt.Printf("name: %s\n", user.Name)
 		fmt.Printf("screen_name: %s\n", user.ScreenName)
+		var badges []string
+		if user.Verified {
+			badges = append(badges, "verified")
+		}
+		if user.Protected {
+			badges = append(badges, "protected")
+		}
+		if len(badges) > 0 {
+			fmt.Printf("badges: %s\n", strings.Join(badges, ", "))
+		}
 		fmt.Printf("followers_count: %d\n", user.FollowersCount)
 		fmt.Printf("friends_count: %d\n", user.FriendsCount)
+		fmt.Printf("statuses_count: %d\n", user.StatusesCount)
+		if user.Location != "" {
+			fmt.Printf("location: %s\n", user.Location)
+		}
+		if u := user.expandedURL(); u != "" {
+			fmt.Printf("url: %s\n", u)
+		}
+		if user.CreatedAt != "" {
+			fmt.Printf("created_at: %s\n", toLocalTime(user.CreatedAt))
+		}
 		fmt.Printf("profile_image_url: %s\n", user.ProfileImageURL)
 		//jsonBytes, err := json.Marshal(user.Description)
 		//if err != nil {
@@ -364,6 +1478,13 @@ func showUser(user User, asjson bool, verbose bool) {
 		//}
 		//fmt.Printf("description: %s\n", string(jsonBytes))
 		fmt.Println("description: " + html.UnescapeString(replacer.Replace(user.Description)))
+		if user.Status != nil {
+			status := *user.Status
+			status.User.Name = user.Name
+			status.User.ScreenName = user.ScreenName
+			fmt.Println("status:")
+			showTweets([]Tweet{status}, false, true)
+		}
 	} else {
 		id := user.Id
 		name := user.Name
@@ -376,8 +1497,44 @@ func showUser(user User, asjson bool, verbose bool) {
 	}
 }
 
+// showUsersCSV prints the users as CSV with a header
+func showUsersCSV(users []User) {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"id", "screen_name", "name", "followers_count", "friends_count", "description", "statuses_count", "verified", "protected", "location", "url", "created_at"})
+	for _, user := range users {
+		w.Write([]string{
+			strconv.Itoa(user.Id),
+			user.ScreenName,
+			user.Name,
+			strconv.Itoa(user.FollowersCount),
+			strconv.Itoa(user.FriendsCount),
+			html.UnescapeString(user.Description),
+			strconv.Itoa(user.StatusesCount),
+			strconv.FormatBool(user.Verified),
+			strconv.FormatBool(user.Protected),
+			user.Location,
+			user.expandedURL(),
+			toLocalTime(user.CreatedAt),
+		})
+	}
+	w.Flush()
+}
+
+// writeRawJSON writes the JSON of the response as it is, to show all fields
+// of the profile, not only which User has
+func writeRawJSON(w io.Writer, raw json.RawMessage) error {
+	_, err := fmt.Fprintln(w, string(raw))
+	return err
+}
+
 func showUsers(users []User, asjson bool, verbose bool) {
-	if asjson {
+	if asCSV && !asjson {
+		showUsersCSV(users)
+	} else if namesOnly && !asjson {
+		for _, user := range users {
+			fmt.Println(user.ScreenName)
+		}
+	} else if asjson {
 		for _, user := range users {
 			json.NewEncoder(os.Stdout).Encode(user)
 			os.Stdout.Sync()
@@ -397,7 +1554,101 @@ func showUsers(users []User, asjson bool, verbose bool) {
 	}
 }
 
-func getConfig(profile string) (string, map[string]string, error) {
+func showFriendship(relationship Relationship, asjson bool) {
+	if asjson {
+		json.NewEncoder(os.Stdout).Encode(relationship)
+		os.Stdout.Sync()
+		return
+	}
+	target := "@" + relationship.Target.ScreenName
+	summary := []string{}
+	if relationship.Source.Following {
+		summary = append(summary, "you follow "+target)
+	} else if relationship.Source.FollowingRequested {
+		summary = append(summary, "you requested to follow "+target)
+	} else {
+		summary = append(summary, "you do not follow "+target)
+	}
+	if relationship.Source.FollowedBy {
+		summary = append(summary, target+" follows you")
+	} else {
+		summary = append(summary, target+" does not follow you")
+	}
+	if relationship.Source.Blocking {
+		summary = append(summary, "you block "+target)
+	}
+	if relationship.Source.BlockedBy {
+		summary = append(summary, target+" blocks you")
+	}
+	if relationship.Source.Muting {
+		summary = append(summary, "you mute "+target)
+	}
+	fmt.Println(strings.Join(summary, "; "))
+}
+
+// showRelationship prints the relationship between two users as a matrix of
+// the directions and flags. Flags unknown for the direction are shown as "-".
+func showRelationship(relationship Relationship, asjson bool) {
+	if asjson {
+		json.NewEncoder(os.Stdout).Encode(relationship)
+		os.Stdout.Sync()
+		return
+	}
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	source, target := "@"+relationship.Source.ScreenName, "@"+relationship.Target.ScreenName
+	rows := [][]string{
+		{"", "following", "requested", "notifications", "blocking", "muting"},
+		{
+			source + " -> " + target,
+			yesNo(relationship.Source.Following),
+			yesNo(relationship.Source.FollowingRequested),
+			yesNo(relationship.Source.NotificationsEnabled),
+			yesNo(relationship.Source.Blocking),
+			yesNo(relationship.Source.Muting),
+		},
+		{
+			target + " -> " + source,
+			yesNo(relationship.Source.FollowedBy),
+			"-",
+			"-",
+			yesNo(relationship.Source.BlockedBy),
+			"-",
+		},
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	for _, row := range rows {
+		var line string
+		for i, cell := range row {
+			line += fmt.Sprintf("%-*s  ", widths[i], cell)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+func showPlaces(places []Place, asjson bool) {
+	if asjson {
+		json.NewEncoder(os.Stdout).Encode(places)
+		os.Stdout.Sync()
+		return
+	}
+	for _, place := range places {
+		fmt.Printf("%s\t%s (%s, %s)\n", place.ID, place.FullName, place.PlaceType, place.Country)
+	}
+}
+
+func configDir() (string, error) {
 	dir := os.Getenv("HOME")
 	if dir == "" && runtime.GOOS == "windows" {
 		dir = os.Getenv("APPDATA")
@@ -409,19 +1660,39 @@ func getConfig(profile string) (string, map[string]string, error) {
 		dir = filepath.Join(dir, ".config", "twty")
 	}
 	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// profileNames returns names of profiles. The default profile is empty.
+func profileNames(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "settings*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range files {
+		name = filepath.Base(name)
+		names = append(names, strings.TrimLeft(name[8:len(name)-5], "-"))
+	}
+	return names, nil
+}
+
+func getConfig(profile string) (string, map[string]string, error) {
+	dir, err := configDir()
+	if err != nil {
 		return "", nil, err
 	}
 	var file string
 	if profile == "" {
 		file = filepath.Join(dir, "settings.json")
 	} else if profile == "?" {
-		names, err := filepath.Glob(filepath.Join(dir, "settings*.json"))
+		names, err := profileNames(dir)
 		if err != nil {
 			return "", nil, err
 		}
 		for _, name := range names {
-			name = filepath.Base(name)
-			name = strings.TrimLeft(name[8:len(name)-5], "-")
 			fmt.Println(name)
 		}
 		os.Exit(0)
@@ -438,7 +1709,7 @@ func getConfig(profile string) (string, map[string]string, error) {
 		config["ClientToken"] = "MbartJkKCrSegn45xK9XLw"
 		config["ClientSecret"] = "1nI3dHFtK9UY1kL6UEYWk6r2lFEcNHWhk7MtXe7eo"
 	} else {
-		err = json.Unmarshal(b, &config)
+		config, err = parseConfig(b)
 		if err != nil {
 			return "", nil, fmt.Errorf("could not unmarshal %v: %v", file, err)
 		}
@@ -446,8 +1717,51 @@ func getConfig(profile string) (string, map[string]string, error) {
 	return file, config, nil
 }
 
+// parseConfig parses the configuration file. Values which are not strings
+// (ex: written by other versions of twty) are ignored instead of failing.
+func parseConfig(b []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	config := map[string]string{}
+	for k, v := range raw {
+		var s string
+		if json.Unmarshal(v, &s) == nil {
+			config[k] = s
+		}
+	}
+	return config, nil
+}
+
+// storeConfig writes the configuration into the file. Values which are not
+// strings in the existing file are kept as they are.
+func storeConfig(file string, config map[string]string) error {
+	raw := map[string]json.RawMessage{}
+	if b, err := ioutil.ReadFile(file); err == nil {
+		json.Unmarshal(b, &raw)
+	}
+	for k, v := range config {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		raw[k] = b
+	}
+	b, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0700)
+}
+
 var (
-	debug bool
+	debug       bool
+	showElapsed bool
+	// namesOnly shows only screen names of users with -names
+	namesOnly bool
+	// asCSV shows users as CSV with -csv
+	asCSV bool
 )
 
 func readFile(filename string) ([]byte, error) {
@@ -499,11 +1813,363 @@ func idToOpt(opt map[string]string, key string, id int64) map[string]string {
 	return opt
 }
 
-// isTimeFormat returns true if the parameter string matches the format like '[0-9]+-[0-9]+-[0-9]+'
-func isTimeFormat(t string) bool {
-	splitFormat := strings.Split(t, "-")
-	if len(splitFormat) != 3 {
-		return false
+// tweetURL returns permalink of the tweet
+func tweetURL(tweet Tweet) string {
+	if tweet.User.ScreenName == "" {
+		return "https://twitter.com/i/web/status/" + tweet.Identifier
+	}
+	return "https://twitter.com/" + tweet.User.ScreenName + "/status/" + tweet.Identifier
+}
+
+const _MaxShortURLRedirs = 3
+
+var (
+	statusURLPattern = regexp.MustCompile(`^https?://(?:(?:www|mobile)\.)?(?:twitter|x)\.com/(?:[^/]+|i/web)/status(?:es)?/([0-9]+)`)
+	shortURLPattern  = regexp.MustCompile(`^https?://t\.co/[0-9A-Za-z]+$`)
+)
+
+// parseTweetID returns ID of the tweet given as ID or permalink. Short URLs
+// of t.co are resolved with HEAD request.
+func parseTweetID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	for i := 0; i < _MaxShortURLRedirs && shortURLPattern.MatchString(s); i++ {
+		u, err := resolveShortURL(s)
+		if err != nil {
+			return "", err
+		}
+		s = u
+	}
+	if m := statusURLPattern.FindStringSubmatch(s); m != nil {
+		return m[1], nil
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+		return "", fmt.Errorf("invalid tweet ID or URL: %q", s)
+	}
+	return s, nil
+}
+
+// searchQuoteTweets searches recent tweets quoting the tweet with its URL,
+// as API v1.1 has no endpoint for quote tweets
+func searchQuoteTweets(token *oauth.Credentials, id string, count string) ([]Tweet, error) {
+	var tweet Tweet
+	err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/show.json", map[string]string{"id": id}, &tweet)
+	if err != nil {
+		return nil, err
+	}
+	res := struct {
+		Statuses []Tweet `json:"statuses"`
+	}{}
+	opt := countToOpt(map[string]string{"q": tweetURL(tweet) + " -filter:retweets"}, count)
+	err = rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/search/tweets.json", opt, &res)
+	if err != nil {
+		return nil, err
+	}
+	var tweets []Tweet
+	for _, t := range res.Statuses {
+		if t.QuotedStatusID == id {
+			tweets = append(tweets, t)
+		}
+	}
+	return tweets, nil
+}
+
+// resolveShortURL returns the URL which the short URL redirects to
+func resolveShortURL(u string) (string, error) {
+	req, err := http.NewRequest(http.MethodHead, u, nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{
+		Timeout: requestTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req.WithContext(requestContext))
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %v: %v", u, err)
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("cannot resolve %v: %v", u, resp.Status)
+	}
+	return location, nil
+}
+
+// targetURL returns URL of the tweet of ID or URL, or the user given as @USER
+func targetURL(target string) (string, error) {
+	if strings.HasPrefix(target, "@") && len(target) > 1 {
+		return "https://twitter.com/" + url.PathEscape(target[1:]), nil
+	}
+	id, err := parseTweetID(target)
+	if err != nil {
+		return "", err
+	}
+	return tweetURL(Tweet{Identifier: id}), nil
+}
+
+// OEmbed hold information about embeddable HTML of the tweet
+type OEmbed struct {
+	URL          string `json:"url"`
+	AuthorName   string `json:"author_name"`
+	AuthorURL    string `json:"author_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	ProviderName string `json:"provider_name"`
+	Version      string `json:"version"`
+}
+
+// oembed fetches oEmbed of the tweet, which does not require authorization
+func oembed(target string, res interface{}) error {
+	u := strings.TrimSpace(target)
+	if !statusURLPattern.MatchString(u) {
+		id, err := parseTweetID(u)
+		if err != nil {
+			return err
+		}
+		u = tweetURL(Tweet{Identifier: id})
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://publish.twitter.com/oembed?"+url.Values{"url": {u}}.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, res)
+}
+
+// lastTweet hold information about the tweet posted last for -open-last
+type lastTweet struct {
+	ID  string `json:"id_str"`
+	URL string `json:"url"`
+}
+
+func loadLastTweet(file string) (lastTweet, error) {
+	var last lastTweet
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return last, fmt.Errorf("no tweet posted yet")
+		}
+		return last, err
+	}
+	if err = json.Unmarshal(b, &last); err != nil {
+		return last, fmt.Errorf("could not unmarshal %v: %v", file, err)
+	}
+	return last, nil
+}
+
+func saveLastTweet(file string, last lastTweet) error {
+	b, err := json.Marshal(last)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0600)
+}
+
+// verifyTweet confirms the posted tweet is available
+func verifyTweet(token *oauth.Credentials, id string) error {
+	if id == "" {
+		return fmt.Errorf("no tweet ID returned")
+	}
+	var tweet Tweet
+	err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/show.json", map[string]string{"id": id}, &tweet)
+	if err != nil {
+		return err
+	}
+	if tweet.Identifier != id {
+		return fmt.Errorf("tweet %v not found", id)
+	}
+	return nil
+}
+
+// mediaOnlyTweets returns tweets which have photos, videos or animated GIFs
+func mediaOnlyTweets(tweets []Tweet) []Tweet {
+	var result []Tweet
+	for _, tweet := range tweets {
+		if len(tweet.ExtendedEntities.Media) > 0 {
+			result = append(result, tweet)
+		}
+	}
+	return result
+}
+
+// langTweets returns tweets written in the language
+func langTweets(tweets []Tweet, lang string) []Tweet {
+	var result []Tweet
+	for _, tweet := range tweets {
+		if tweet.Lang == lang {
+			result = append(result, tweet)
+		}
+	}
+	return result
+}
+
+// originalTweets removes replies if noReplies is true, and retweets if noRTs
+// is true
+func originalTweets(tweets []Tweet, noReplies bool, noRTs bool) []Tweet {
+	var result []Tweet
+	for _, tweet := range tweets {
+		if noReplies && tweet.InReplyToStatusID != "" {
+			continue
+		}
+		if noRTs && tweet.RetweetedStatus != nil {
+			continue
+		}
+		result = append(result, tweet)
+	}
+	return result
+}
+
+// dedupeTweets removes tweets which have same ID, preserving order
+func dedupeTweets(tweets []Tweet) []Tweet {
+	seen := make(map[string]bool)
+	var result []Tweet
+	for _, tweet := range tweets {
+		if seen[tweet.Identifier] {
+			continue
+		}
+		seen[tweet.Identifier] = true
+		result = append(result, tweet)
+	}
+	return result
+}
+
+// sortTweetsByTime sorts tweets from newest to oldest like timelines
+func sortTweetsByTime(tweets []Tweet) {
+	sort.SliceStable(tweets, func(i, j int) bool {
+		ti, _ := time.Parse(_TimeLayout, tweets[i].CreatedAt)
+		tj, _ := time.Parse(_TimeLayout, tweets[j].CreatedAt)
+		return ti.After(tj)
+	})
+}
+
+// fetchPages calls fetch repeatedly, moving max_id to older tweets, until count
+// tweets are fetched, no more tweets are returned, or pages are fetched if
+// pages is positive. Tweets returned twice are skipped.
+func fetchPages(opt map[string]string, count int, pageSize int, pages int, fetch func(map[string]string) ([]Tweet, error)) ([]Tweet, error) {
+	if count <= 0 {
+		if pages <= 0 {
+			return fetch(opt)
+		}
+		count = pageSize * pages
+	}
+	var tweets []Tweet
+	seen := make(map[string]bool)
+	for page := 0; len(tweets) < count && (pages <= 0 || page < pages); page++ {
+		n := count - len(tweets)
+		if n > pageSize {
+			n = pageSize
+		}
+		opt["count"] = strconv.Itoa(n)
+		res, err := fetch(opt)
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, tweet := range res {
+			if seen[tweet.Identifier] {
+				continue
+			}
+			seen[tweet.Identifier] = true
+			tweets = append(tweets, tweet)
+			added++
+		}
+		_, minID := tweetIDRange(res)
+		if added == 0 || minID <= 1 {
+			break
+		}
+		opt["max_id"] = strconv.FormatInt(minID-1, 10)
+	}
+	if len(tweets) > count {
+		tweets = tweets[:count]
+	}
+	return tweets, nil
+}
+
+// engagement returns total of reply, retweet, quote and favorite counts
+func engagement(tweet Tweet) int {
+	n := tweet.RetweetCount + tweet.FavoriteCount
+	if tweet.ReplyCount != nil {
+		n += *tweet.ReplyCount
+	}
+	if tweet.QuoteCount != nil {
+		n += *tweet.QuoteCount
+	}
+	return n
+}
+
+// sortTweetsByEngagement sorts tweets from most engaged to least, so that
+// the most engaged tweet is shown at the bottom like the newest in timelines
+func sortTweetsByEngagement(tweets []Tweet) {
+	sort.SliceStable(tweets, func(i, j int) bool {
+		return engagement(tweets[i]) > engagement(tweets[j])
+	})
+}
+
+// tweetIDRange returns the maximum and minimum IDs of the tweets
+func tweetIDRange(tweets []Tweet) (int64, int64) {
+	var maxID, minID int64
+	for _, tweet := range tweets {
+		id, err := strconv.ParseInt(tweet.Identifier, 10, 64)
+		if err != nil {
+			continue
+		}
+		if maxID == 0 || id > maxID {
+			maxID = id
+		}
+		if minID == 0 || id < minID {
+			minID = id
+		}
+	}
+	return maxID, minID
+}
+
+// agoString returns human readable duration like "2h ago"
+func agoString(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	}
+}
+
+// tweetsFooter returns summary of the tweets like "— 42 tweets, oldest 2h ago —"
+func tweetsFooter(tweets []Tweet, now time.Time) string {
+	var oldest time.Time
+	for _, tweet := range tweets {
+		t, err := time.Parse(_TimeLayout, tweet.CreatedAt)
+		if err != nil {
+			continue
+		}
+		if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+	}
+	summary := fmt.Sprintf("%d tweets", len(tweets))
+	if len(tweets) == 1 {
+		summary = "1 tweet"
+	}
+	if !oldest.IsZero() {
+		summary += ", oldest " + agoString(now.Sub(oldest))
+	}
+	return "\u2014 " + summary + " \u2014"
+}
+
+// isTimeFormat returns true if the parameter string matches the format like '[0-9]+-[0-9]+-[0-9]+'
+func isTimeFormat(t string) bool {
+	splitFormat := strings.Split(t, "-")
+	if len(splitFormat) != 3 {
+		return false
 	}
 
 	for _, v := range splitFormat {
@@ -523,26 +2189,220 @@ func main() {
 	var asjson bool
 	var user string
 	var favorite string
+	var unfavorite string
+	var likes string
+	var bookmark string
+	var unbookmark string
+	var bookmarks bool
 	var search string
 	var inreply string
 	var media files
+	var alts files
+	var sensitive bool
+	var copyURL bool
+	var replyAll bool
+	var maxUploadSize string
+	var noWait bool
 	var verbose bool
 	var show_user string
+	var showUserID string
 	var search_user string
+	var mediaOnly bool
+	var noReplies bool
+	var noRTs bool
+	var sortBy string
+	var showContext bool
+	var lang string
+	var dedupe bool
+	var useV2 bool
+	var api string
+	var status string
+	var thread string
+	var open string
+	var openLast bool
+	var embed string
+	var quotes string
+	var deleteID string
+	var yes bool
+	var friendship string
+	var allAccounts bool
+	var footer bool
+	var expectMin int
+	var showIDs bool
+	var accessToken string
+	var accessSecret string
+	var saveToken bool
+	var verify bool
+	var help bool
+	var detectLang bool
+	var followBack bool
+	var dryRun bool
+	var quote string
+	var newOnly bool
+	var resetSeen bool
+	var listSeen bool
+	var label bool
+	var markdown bool
+	var template string
+	var stdinJSON bool
+	var deadline time.Duration
+	var entities bool
+	var reauthorize bool
+	var countChars bool
+	var followRequests bool
+	var accept files
+	var deny files
+	var cw string
+	var poll string
+	var lat string
+	var long string
+	var place string
+	var placeSearch string
+	var replyTo string
+	var pollDuration int
+	var trim bool
+	var blocked bool
+	var muted bool
+	var follow files
+	var unfollow files
+	var block files
+	var unblock files
+	var mute files
+	var unmute files
+	var report files
+	var performBlock bool
+	var followFile string
+	var unfollowNonmutual bool
+	var snapshotFollowers bool
+	var interval time.Duration
+	var result string
+	var followers string
+	var following string
+	var relationship string
+	var mutuals string
+	var lookup bool
+	var lookupFile string
+	var listCreate string
+	var listUpdate string
+	var listDestroy string
+	var listName string
+	var listDescription string
+	var private bool
+	var public bool
 
 	flag.StringVar(&profile, "a", "", "account")
+	flag.BoolVar(&label, "label", false, "prefix tweets with profile name")
+	flag.BoolVar(&help, "help", false, "show usage, or usage of the command given as argument")
+	flag.StringVar(&accessToken, "token", "", "access token")
+	flag.StringVar(&accessSecret, "token-secret", "", "access token secret")
+	flag.BoolVar(&saveToken, "save-token", false, "store access token given with -token")
+	flag.BoolVar(&reauthorize, "reauthorize", false, "authorize again with the approval screen")
 	flag.BoolVar(&reply, "r", false, "show replies")
 	flag.StringVar(&list, "l", "", "show tweets")
+	flag.StringVar(&listCreate, "list-create", "", "create list")
+	flag.StringVar(&listUpdate, "list-update", "", "update list")
+	flag.StringVar(&listDestroy, "list-destroy", "", "delete list")
+	flag.StringVar(&listName, "list-name", "", "new name of list for -list-update")
+	flag.StringVar(&listDescription, "list-description", "", "description of list")
+	flag.BoolVar(&private, "private", false, "make list private")
+	flag.BoolVar(&public, "public", false, "make list public")
 	flag.BoolVar(&asjson, "json", false, "show tweets as json")
+	flag.BoolVar(&markdown, "md", false, "show tweets as markdown")
+	flag.BoolVar(&entities, "show-entities", false, "show entities of tweets")
 	flag.StringVar(&user, "u", "", "show user timeline")
 	flag.StringVar(&favorite, "f", "", "specify favorite ID")
+	flag.StringVar(&unfavorite, "unfav", "", "specify ID to unfavorite")
+	flag.StringVar(&likes, "likes", "", "show tweets liked by user")
+	flag.StringVar(&bookmark, "bookmark", "", "add the tweet to bookmarks")
+	flag.StringVar(&unbookmark, "unbookmark", "", "remove the tweet from bookmarks")
+	flag.BoolVar(&bookmarks, "bookmarks", false, "show bookmarks")
+	flag.StringVar(&status, "status", "", "show the tweet of ID or URL")
+	flag.StringVar(&thread, "thread", "", "show the thread of the tweet")
+	flag.StringVar(&open, "open", "", "open the tweet or @user in the browser")
+	flag.BoolVar(&openLast, "open-last", false, "open the tweet posted last in the browser")
+	flag.StringVar(&embed, "embed", "", "show embeddable HTML of the tweet")
+	flag.StringVar(&quotes, "quotes", "", "show quote tweets of the tweet")
+	flag.StringVar(&deleteID, "delete", "", "delete the tweet (\"-\" reads IDs from STDIN)")
+	flag.BoolVar(&yes, "y", false, "do not ask confirmation")
 	flag.StringVar(&search, "s", "", "search word")
 	flag.StringVar(&inreply, "i", "", "specify in-reply ID, if not specify text, it will be RT.")
+	flag.BoolVar(&replyAll, "reply-all", false, "mention the author and users mentioned in the tweet of -i")
 	flag.Var(&media, "m", "upload media")
+	flag.Var(&alts, "alt", "alt text of media of -m in the same order")
+	flag.BoolVar(&sensitive, "sensitive", false, "mark media of the tweet as sensitive")
+	flag.BoolVar(&imagePreprocess.stripExif, "strip-exif", false, "remove EXIF and GPS metadata from images before uploading")
+	flag.StringVar(&maxUploadSize, "max-upload-size", "", "re-encode images larger than the size before uploading (ex: 5MB)")
+	flag.IntVar(&imagePreprocess.maxDimension, "max-dimension", 0, "scale down images larger than the pixels before uploading")
+	flag.StringVar(&quote, "q", "", "specify quoted tweet ID")
+	flag.StringVar(&quote, "quote", "", "specify quoted tweet ID (same as -q)")
+	flag.StringVar(&cw, "cw", "", "content warning")
+	flag.StringVar(&poll, "poll", "", "comma separated options of poll")
+	flag.StringVar(&lat, "lat", "", "latitude of the tweet")
+	flag.StringVar(&long, "long", "", "longitude of the tweet")
+	flag.StringVar(&place, "place", "", "place ID of the tweet")
+	flag.StringVar(&placeSearch, "place-search", "", "search places")
+	flag.StringVar(&replyTo, "reply-to", "", "who can reply to the tweet (everyone, following or mentioned)")
+	flag.IntVar(&pollDuration, "poll-duration", 1440, "duration of poll in minutes")
+	flag.BoolVar(&noWait, "no-wait", false, "do not wait for processing of uploaded video")
 	flag.BoolVar(&verbose, "v", false, "detail display")
 	flag.BoolVar(&debug, "debug", false, "debug json")
+	flag.IntVar(&breaker.maxFailures, "max-consecutive-failures", 5, "cool off after consecutive failures of API calls (0 disables)")
+	flag.DurationVar(&breaker.cooloff, "cooloff", time.Minute, "duration to cool off after consecutive failures")
+	flag.DurationVar(&requestTimeout, "timeout-per-try", 0, "timeout of each API call")
+	flag.DurationVar(&deadline, "deadline", 0, "timeout of all API calls in total")
 	flag.StringVar(&show_user, "show_user", "", "show user profile")
+	flag.StringVar(&showUserID, "show_user_id", "", "show user profile of ID")
 	flag.StringVar(&search_user, "search_user", "", "search users")
+	flag.BoolVar(&mediaOnly, "media-only", false, "show only tweets with media")
+	flag.BoolVar(&noReplies, "no-replies", false, "exclude replies")
+	flag.BoolVar(&noRTs, "no-rts", false, "exclude retweets")
+	flag.StringVar(&sortBy, "sort", "", "sort tweets by time or engagement")
+	flag.BoolVar(&showContext, "context", false, "show tweets replied to")
+	flag.StringVar(&lang, "lang", "", "show only tweets in the language")
+	flag.BoolVar(&dedupe, "dedupe", true, "remove duplicated tweets")
+	flag.BoolVar(&footer, "footer", false, "show summary of tweets")
+	flag.BoolVar(&newOnly, "new", false, "show tweets not seen yet")
+	flag.BoolVar(&resetSeen, "reset-seen", false, "reset seen markers of -new")
+	flag.BoolVar(&listSeen, "list-seen", false, "show seen markers of -new")
+	flag.IntVar(&expectMin, "expect-min", 0, "exit with error if fewer tweets are fetched")
+	flag.BoolVar(&showIDs, "show-ids", false, "show maximum and minimum IDs of tweets")
+	flag.BoolVar(&useV2, "v2", false, "use API v2 for search")
+	flag.StringVar(&api, "api", "", "version of API to use (1.1 or v2)")
+	flag.StringVar(&friendship, "friendship", "", "show friendship with user")
+	flag.StringVar(&relationship, "relationship", "", "show relationship between two users")
+	flag.StringVar(&mutuals, "mutuals", "", "show users who follow both of two users")
+	flag.BoolVar(&allAccounts, "all-accounts", false, "show timelines of all profiles")
+	flag.BoolVar(&blocked, "blocked", false, "show blocked users")
+	flag.BoolVar(&blocked, "blocks", false, "show blocked users")
+	flag.Var(&block, "block", "block user")
+	flag.Var(&unblock, "unblock", "unblock user")
+	flag.BoolVar(&muted, "muted", false, "show muted users")
+	flag.BoolVar(&muted, "mutes", false, "show muted users")
+	flag.Var(&mute, "mute", "mute user")
+	flag.Var(&unmute, "unmute", "unmute user")
+	flag.Var(&report, "report", "report user as spam")
+	flag.BoolVar(&performBlock, "perform-block", false, "block users reported with -report")
+	flag.BoolVar(&followBack, "follow-back", false, "follow followers who you do not follow")
+	flag.Var(&follow, "follow", "follow user")
+	flag.Var(&unfollow, "unfollow", "unfollow user")
+	flag.StringVar(&followFile, "follow-file", "", "follow users in file")
+	flag.BoolVar(&unfollowNonmutual, "unfollow-nonmutual", false, "unfollow users who do not follow you")
+	flag.BoolVar(&snapshotFollowers, "snapshot-followers", false, "show who followed and unfollowed since the last snapshot")
+	flag.DurationVar(&interval, "interval", _FollowInterval, "interval between follows, blocks and so on")
+	flag.StringVar(&result, "result", "", "write result of follows, blocks and so on to file")
+	flag.StringVar(&followers, "followers", "", "show followers of user")
+	flag.StringVar(&following, "following", "", "show users who user follows")
+	flag.BoolVar(&namesOnly, "names", false, "show only screen names of users")
+	flag.BoolVar(&asCSV, "csv", false, "show users as CSV")
+	flag.BoolVar(&lookup, "lookup", false, "show users of screen names or IDs in arguments")
+	flag.StringVar(&lookupFile, "lookup-file", "", "show users of screen names or IDs in file")
+	flag.BoolVar(&followRequests, "follow-requests", false, "show pending follow requests")
+	flag.BoolVar(&followRequests, "requests", false, "show pending follow requests")
+	flag.Var(&accept, "accept", "accept follow request of user")
+	flag.Var(&deny, "deny", "deny follow request of user")
+	flag.BoolVar(&dryRun, "dry-run", false, "show what will be done without doing it")
+	flag.BoolVar(&dryRun, "n", false, "show what will be done without doing it")
+	flag.BoolVar(&confirmWrites, "confirm", false, "ask confirmation before changing anything")
 
 	var fromfile string
 	var count string
@@ -550,161 +2410,1622 @@ func main() {
 	var until string
 	var sinceID int64
 	var maxID int64
+	var limit int
+	var pages int
+	var compose bool
+	var interactive bool
+	var split bool
+	var draft string
+	var queue bool
+	var flush bool
+	var scheduleAt string
+	var delay string
+	var runScheduler bool
+	var scheduler bool
+	var raw string
+	rawParams := params{}
 
 	flag.StringVar(&fromfile, "ff", "", "post utf-8 string from a file(\"-\" means STDIN)")
+	flag.BoolVar(&trim, "trim", false, "strip spaces around the text of -ff")
+	flag.BoolVar(&compose, "compose", false, "compose a tweet with $EDITOR")
+	flag.BoolVar(&interactive, "interactive", false, "compose a tweet with character count")
+	flag.BoolVar(&split, "split", false, "split long text into a thread")
+	flag.StringVar(&draft, "draft", "", "manage drafts (save, list, edit, post or delete)")
+	flag.BoolVar(&queue, "queue", false, "queue the tweet to post later with -flush")
+	flag.BoolVar(&flush, "flush", false, "post queued tweets")
+	flag.StringVar(&delay, "delay", "", "wait before posting to cancel with Ctrl-C (ex: 10s)")
+	flag.StringVar(&scheduleAt, "schedule", "", "schedule the tweet at the time (or list, cancel)")
+	flag.BoolVar(&runScheduler, "run-scheduler", false, "post scheduled tweets whose time has arrived")
+	flag.BoolVar(&scheduler, "scheduler", false, "run scheduler to post scheduled tweets")
+	flag.StringVar(&template, "template", "", "post a tweet from template")
+	flag.BoolVar(&stdinJSON, "stdin-json", false, "post tweets described as JSON from STDIN")
+	flag.BoolVar(&verify, "verify", false, "verify the tweet exists after posting")
+	flag.BoolVar(&copyURL, "copy", false, "copy URL of the posted tweet to clipboard")
+	flag.BoolVar(&detectLang, "detect-lang", false, "show language of the text without posting")
+	flag.BoolVar(&countChars, "count-chars", false, "show length of the text without posting")
+	flag.StringVar(&raw, "raw", "", "call arbitrary API (advanced)")
+	flag.Var(rawParams, "param", "parameter for -raw")
 	flag.StringVar(&count, "count", "", "fetch tweets count")
 	flag.StringVar(&since, "since", "", "fetch tweets since date.")
 	flag.StringVar(&until, "until", "", "fetch tweets until date.")
 	flag.Int64Var(&sinceID, "since_id", 0, "fetch tweets that id is greater than since_id.")
 	flag.Int64Var(&maxID, "max_id", 0, "fetch tweets that id is lower than max_id.")
+	flag.IntVar(&limit, "limit", 0, "limit total number of users.")
+	flag.IntVar(&pages, "pages", 0, "limit number of pages to fetch.")
 
 	flag.Usage = func() {
 		fmt.Fprint(os.Stderr, `Usage of twty:
   -a PROFILE: switch profile to load configuration file.
+  -label: prefix tweets with the profile name given with -a.
+  -token TOKEN: use access token instead of configuration file.
+                (or environment variable TWTY_ACCESS_TOKEN)
+  -token-secret SECRET: use access token secret instead of configuration file.
+                (or environment variable TWTY_ACCESS_SECRET)
+  -save-token: store access token given with -token into configuration file.
+  -reauthorize: authorize again, always showing the approval screen to choose the account.
   -f ID: specify favorite ID
+  -unfav ID: undo favorite of the tweet
+  -bookmark ID: add the tweet to bookmarks (requires ClientID of OAuth 2.0)
+  -unbookmark ID: remove the tweet from bookmarks
+  -bookmarks: show bookmarks
   -i ID: specify in-reply ID, if not specify text, it will be RT.
+  -reply-all: mention the author and users mentioned in the tweet of -i, except you
   -l USER/LIST: show list's timeline (ex: mattn_jp/subtech)
+  -list-create NAME: create a list
+  -list-update LIST: update name (-list-name), description or mode of your list
+  -list-destroy LIST: delete your list after confirmation
+  -list-name NAME: new name of the list for -list-update
+  -list-description TEXT: description of the list for -list-create and -list-update
+  -private, -public: make the list private or public for -list-create and -list-update
   -m FILE: upload media
+  -alt TEXT: alt text of media, matched to -m in the same order
+  -sensitive: mark media of the tweet as possibly sensitive (API v1.1 only)
+  -strip-exif: remove EXIF (including GPS location), XMP and IPTC metadata from JPEG and PNG before uploading
+  -max-upload-size SIZE: re-encode images larger than SIZE (ex: 5MB) as JPEG, scaling down if needed
+  -max-dimension PIXELS: scale down images wider or taller than PIXELS before uploading
+  -q ID, -quote ID: quote the tweet, ID can be URL of the tweet. can be combined with -m
+  -cw TEXT: prepend content warning like "CW: TEXT" to the tweet
+  -reply-to WHO: who can reply to the tweet, everyone, following or mentioned. posted with API v2
+  -lat LATITUDE, -long LONGITUDE: geotag the tweet with the coordinates (API v1.1 only)
+  -place PLACE_ID: geotag the tweet with the place. find PLACE_ID with -place-search
+  -place-search WORD: search places to get PLACE_ID (near -lat and -long if specified)
+  -poll OPTIONS: attach a poll of 2 to 4 comma separated options (ex: "yes,no"), posted with API v2
+  -poll-duration MINUTES: duration of the poll, 5 to 10080 minutes (default 1440)
+  -no-wait: do not wait for processing of uploaded video. posting the tweet
+            may fail if the processing fails.
   -u USER: show user's timeline
+  -likes USER: show tweets liked by USER
+  -status ID: show the tweet in detail, ID can be URL of the tweet
+  -thread ID: show the thread containing the tweet as a tree
+  -open ID|@USER: open the tweet or the user in the browser
+  -open-last: open the tweet posted last in the browser
+  -embed ID: show embeddable HTML of the tweet (oEmbed JSON with -json)
+  -quotes ID: show tweets quoting the tweet
+  -delete ID: delete your tweet after confirmation. "-" reads IDs from STDIN (requires -y)
+  -y: do not ask confirmation
   -s WORD: search timeline
+  -v2: search with API v2 (requires BearerToken in configuration file)
+  -api VERSION: version of API to use, "1.1" or "v2" (default "1.1", or API in configuration file)
   -json: as JSON
+  -md: as Markdown
+  -show-entities: show hashtags, mentions, URLs and media of tweets
   -r: show replies
+  -all-accounts: show home timelines of all profiles merged
   -v: detail display
-  -ff FILENAME: post utf-8 string from a file("-" means STDIN)
-  -count NUMBER: show NUMBER tweets at timeline.
+  -ff FILENAME: post utf-8 string from a file("-" means STDIN). a trailing newline is removed.
+  -trim: strip all spaces and newlines around the text of -ff
+  -compose: compose a tweet with $EDITOR, lines starting with '#' are ignored
+  -interactive: compose a tweet line by line, showing characters left
+  -split: split text (or -ff) longer than 280 characters into a numbered thread.
+          -dry-run shows the split tweets without posting.
+  -template NAME: post a tweet from template in configuration file
+  -delay DURATION: show the tweet and wait DURATION before posting, to cancel with Ctrl-C (ex: 10s)
+  -queue: queue the tweet (TEXT, -m and -i) to post later without posting
+  -flush: post queued tweets in order. -dry-run shows them without posting.
+  -schedule TIME: schedule the tweet (TEXT, -m and -i) at TIME (ex: "2024-07-01 09:00", "09:00", "+2h").
+                  "-schedule list" shows scheduled tweets, and "-schedule cancel ID" cancels one.
+  -run-scheduler: post scheduled tweets whose time has arrived, suitable for cron
+  -scheduler: keep running to post scheduled tweets at their time
+  -draft COMMAND: manage drafts. COMMAND is save [TEXT], list, edit ID, post ID or delete ID
+  -stdin-json: post tweets described as JSON from STDIN
+  -verify: confirm the posted tweet is available
+  -copy: copy URL of the posted tweet to clipboard
+  -detect-lang: show guessed language of the text (or -ff, -compose) without posting
+  -count-chars: show length of the text (or STDIN) counted as twitter does without posting
+  -count NUMBER: show NUMBER tweets at timeline, fetching pages as needed.
+  -pages NUMBER: fetch at most NUMBER pages of timeline.
   -since DATE: show tweets created after the DATE (ex. 2017-05-01)
   -until DATE: show tweets created before the DATE (ex. 2017-05-31)
   -since_id NUMBER: show tweets that have ids greater than NUMBER.
   -max_id NUMBER: show tweets that have ids lower than NUMBER.
+  -limit NUMBER: show at most NUMBER users.
+  -max-consecutive-failures NUMBER: cool off after NUMBER consecutive failures of
+      API calls, and give up if they continue after cooling off (default 5, 0 disables)
+  -cooloff DURATION: duration to cool off (default 1m)
+  -timeout-per-try DURATION: timeout of each API call (ex: 10s)
+  -deadline DURATION: timeout of all API calls in total, including retries and pages (ex: 1m)
   -show_user USER: show user profile
+  -show_user_id ID: show user profile of user ID
   -search_user SEARCHWORD: search users
+  -friendship USER: show friendship between you and USER
+  -relationship USER1 USER2: show relationship between USER1 and USER2
+  -mutuals USER1 USER2: show users who follow both USER1 and USER2
+  -blocked, -blocks: show users you block
+  -block USER: block USER (can be specified multiple times, separated by commas, or "-" to read from STDIN)
+  -unblock USER: unblock USER (can be specified multiple times, separated by commas, or "-" to read from STDIN)
+  -muted, -mutes: show users you mute
+  -mute USER: mute USER (can be specified multiple times, separated by commas, or "-" to read from STDIN)
+  -unmute USER: unmute USER (can be specified multiple times, separated by commas, or "-" to read from STDIN)
+  -report USER: report USER as spam (can be specified multiple times, separated by commas, or "-" to read from STDIN)
+  -perform-block: block users reported with -report as well
+  -follow USER: follow USER (can be specified multiple times, separated by commas, or "-" to read from STDIN)
+  -unfollow USER: unfollow USER (can be specified multiple times, separated by commas, or "-" to read from STDIN)
+  -follow-file FILE: follow users in FILE, a screen name or user ID per line
+  -interval DURATION: interval between follows, blocks and so on (default 1s)
+  -result FILE: write result of each user of follows, blocks and so on to FILE
+  -followers USER: show followers of USER (or yourself with "me"), up to -count users
+  -following USER: show users who USER (or yourself with "me") follows, up to -count users
+  -names: show only screen names of users, one per line
+  -csv: show users as CSV
+  -lookup USER...: show users of screen names or IDs in arguments (or STDIN without arguments)
+  -lookup-file FILE: show users of screen names or IDs in FILE, one per line
+  -follow-back: follow your followers who you do not follow yet
+  -unfollow-nonmutual: unfollow users who do not follow you back, resuming if it was interrupted
+  -snapshot-followers: save your followers, and show who followed and unfollowed you since the last snapshot
+  -follow-requests, -requests: show pending follow requests (for protected accounts)
+  -accept USER: accept follow request of USER (can be specified multiple times, separated by commas, or "-" to read from STDIN)
+  -deny USER: deny follow request of USER (can be specified multiple times, separated by commas, or "-" to read from STDIN)
+  -dry-run, -n: show what will be done without doing it. API calls which change anything (tweet,
+               favorite, retweet, delete and so on) are shown with parameters and media instead of sent.
+  -confirm: show API calls which change anything and ask confirmation before sending them
+  -media-only: show only tweets with photos, videos or GIFs
+  -no-replies: exclude replies
+  -no-rts: exclude retweets
+  -context: show the tweet replied to above each reply
+  -lang LANG: show only tweets in LANG (ex: ja, en)
+  -sort KEY: sort tweets by "time" or "engagement" (replies, retweets, quotes and favorites)
+  -dedupe=false: show raw results without removing duplicated tweets
+  -footer: show number of tweets and how old the oldest one is
+  -new: show only tweets newer than the last seen in the timeline
+  -reset-seen [TIMELINE]: reset seen markers of -new (all timelines if omitted)
+  -list-seen: show seen markers of -new
+  -expect-min NUMBER: exit with error if fewer than NUMBER tweets are shown
+  -show-ids: show maximum and minimum IDs of tweets for -since_id/-max_id (implied by -v)
+
+Advanced (unsupported):
+  -raw METHOD:ENDPOINT: call the API and dump JSON (ex: GET:1.1/trends/available.json)
+  -param KEY=VALUE: parameter for -raw, can be specified multiple times
 `)
+		fmt.Fprintf(os.Stderr, "\nRun 'twty -help COMMAND' for details of commands: %s\n", strings.Join(commandNames(), ", "))
 	}
 	flag.Parse()
+	dryRunWrites = dryRun
+	showProgress = !asjson && isTerminal(os.Stdout)
+
+	if followFile != "" {
+		names, err := readUserList(followFile)
+		if err != nil {
+			log.Fatal("cannot read users:", err)
+		}
+		follow = append(follow, names...)
+	}
+	if interval < 0 {
+		log.Fatal("-interval must not be negative")
+	}
+	if showUserID != "" {
+		if _, err := strconv.ParseInt(showUserID, 10, 64); err != nil {
+			log.Fatal("invalid user ID:", showUserID)
+		}
+	}
+
+	// actions of -follow, -block and so on which are specified
+	var userActions []userAction
+	for _, action := range []userAction{
+		{"follow", "https://api.twitter.com/1.1/friendships/create.json", "followed", follow, nil},
+		{"unfollow", "https://api.twitter.com/1.1/friendships/destroy.json", "unfollowed", unfollow, nil},
+		{"block", "https://api.twitter.com/1.1/blocks/create.json", "blocked", block, nil},
+		{"unblock", "https://api.twitter.com/1.1/blocks/destroy.json", "unblocked", unblock, nil},
+		{"mute", "https://api.twitter.com/1.1/mutes/users/create.json", "muted", mute, nil},
+		{"unmute", "https://api.twitter.com/1.1/mutes/users/destroy.json", "unmuted", unmute, nil},
+		{"report", "https://api.twitter.com/1.1/users/report_spam.json", "reported", report, map[string]string{"perform_block": strconv.FormatBool(performBlock)}},
+		{"accept", "https://api.twitter.com/1.1/friendships/accept.json", "accepted", accept, nil},
+		{"deny", "https://api.twitter.com/1.1/friendships/deny.json", "denied", deny, nil},
+	} {
+		if len(action.users) > 0 {
+			userActions = append(userActions, action)
+		}
+	}
 
+	if help {
+		if flag.NArg() == 0 {
+			flag.Usage()
+			os.Exit(0)
+		}
+		showCommandHelp(flag.Arg(0))
+	}
+	if sortBy != "" && sortBy != "time" && sortBy != "engagement" {
+		log.Fatalf("unknown sort key %q: use time or engagement", sortBy)
+	}
+	showElapsed = verbose || debug
+	if deadline > 0 {
+		var cancel context.CancelFunc
+		requestContext, cancel = context.WithTimeout(context.Background(), deadline)
+		defer cancel()
+	}
 	os.Setenv("GODEBUG", os.Getenv("GODEBUG")+",http2client=0")
 
+	filterTweets := func(tweets []Tweet) []Tweet {
+		if dedupe {
+			tweets = dedupeTweets(tweets)
+		}
+		if noReplies || noRTs {
+			tweets = originalTweets(tweets, noReplies, noRTs)
+		}
+		if lang != "" {
+			tweets = langTweets(tweets, lang)
+		}
+		if mediaOnly {
+			tweets = mediaOnlyTweets(tweets)
+		}
+		switch sortBy {
+		case "time":
+			sortTweetsByTime(tweets)
+		case "engagement":
+			sortTweetsByEngagement(tweets)
+		}
+		return tweets
+	}
+	// getTweet fetches the tweet of the ID, which is available after authorization
+	var getTweet func(id string) (Tweet, error)
+	renderTweets := func(tweets []Tweet) {
+		tweets = filterTweets(tweets)
+		if showContext && !asjson {
+			fetchReplyContext(tweets, getTweet)
+		}
+		if label && profile != "" && !asjson {
+			for i := range tweets {
+				// tweets of -all-accounts are labeled with their profiles
+				if tweets[i].Profile == "" {
+					tweets[i].Profile = profile
+				}
+			}
+		}
+		if markdown && !asjson {
+			showMarkdown(tweets)
+		} else if entities && !asjson {
+			showEntities(tweets)
+		} else {
+			showTweets(tweets, asjson, verbose)
+		}
+		if footer && !asjson {
+			fmt.Println(tweetsFooter(tweets, time.Now()))
+		}
+		if (verbose || showIDs) && !asjson && len(tweets) > 0 {
+			maxID, minID := tweetIDRange(tweets)
+			fmt.Printf("max_id=%d min_id=%d\n", maxID, minID)
+		}
+		if len(tweets) < expectMin {
+			fmt.Fprintf(os.Stderr, "expected at least %d tweets, but got %d\n", expectMin, len(tweets))
+			os.Exit(1)
+		}
+	}
+
 	file, config, err := getConfig(profile)
 	if err != nil {
 		log.Fatal("cannot get configuration:", err)
 	}
-	token, authorized, err := getAccessToken(config)
-	if err != nil {
-		log.Fatal("cannot get access token:", err)
+	loadPrompts(config)
+	if api == "" {
+		api = config["API"]
+	}
+	switch api {
+	case "", "1.1":
+		api = "1.1"
+	case "v2", "2":
+		api = "v2"
+	default:
+		log.Fatalf("unknown API version %q: use 1.1 or v2", api)
+	}
+	if delay == "" {
+		delay = config["PostDelay"]
+	}
+	if maxUploadSize != "" {
+		if imagePreprocess.maxSize, err = parseSize(maxUploadSize); err != nil {
+			log.Fatal("invalid -max-upload-size:", err)
+		}
+	}
+	if err = validateAltTexts(alts, media); err != nil {
+		log.Fatal("invalid alt text:", err)
+	}
+	replySettings, ok := replySettingsV2[replyTo]
+	if replyTo != "" && !ok {
+		log.Fatalf("unknown -reply-to %q: use everyone, following or mentioned", replyTo)
+	}
+	if lat != "" || long != "" {
+		if err = validateCoordinates(lat, long); err != nil {
+			log.Fatal("invalid coordinates:", err)
+		}
+		if api == "v2" || poll != "" || replySettings != "" {
+			log.Fatal("invalid coordinates: -lat and -long are not available with API v2, use -place instead")
+		}
+	}
+	if sensitive && (api == "v2" || poll != "" || replySettings != "") {
+		log.Fatal("-sensitive is not available with API v2")
+	}
+	var pollOptions []string
+	if poll != "" {
+		if pollOptions, err = parsePoll(poll, pollDuration); err != nil {
+			log.Fatal("invalid poll:", err)
+		}
+		if len(media) > 0 {
+			log.Fatal("invalid poll: media cannot be attached with a poll")
+		}
+	}
+	var postDelay time.Duration
+	if delay != "" {
+		if postDelay, err = time.ParseDuration(delay); err != nil {
+			log.Fatalf("invalid delay %q: %v", delay, err)
+		}
 	}
-	if authorized {
-		b, err := json.MarshalIndent(config, "", "  ")
+
+	var seen *seenMarkers
+	if newOnly || resetSeen || listSeen {
+		seen, err = loadSeenMarkers(stateFile(file, profile, "seen"))
 		if err != nil {
-			log.Fatal("cannot store file:", err)
+			log.Fatal("cannot load seen markers:", err)
+		}
+	}
+	if listSeen {
+		for _, name := range seen.names() {
+			fmt.Printf("%s\t%d\n", name, seen.get(name))
+		}
+		os.Exit(0)
+	}
+	if resetSeen {
+		timeline := flag.Arg(0)
+		question := "Reset all seen markers?"
+		if timeline != "" {
+			question = "Reset seen marker of " + timeline + "?"
+		}
+		if !confirm(question) {
+			os.Exit(1)
+		}
+		seen.reset(timeline)
+		if err = seen.save(); err != nil {
+			log.Fatal("cannot store seen markers:", err)
+		}
+		os.Exit(0)
+	}
+
+	if open != "" || openLast {
+		var u string
+		if openLast {
+			var last lastTweet
+			last, err = loadLastTweet(stateFile(file, profile, "last-tweet"))
+			u = last.URL
+		} else {
+			u, err = targetURL(open)
 		}
-		err = ioutil.WriteFile(file, b, 0700)
 		if err != nil {
-			log.Fatal("cannot store file:", err)
+			log.Fatal("cannot open:", err)
+		}
+		if err = openURL(u, ""); err != nil {
+			log.Fatal("cannot open:", err)
 		}
+		os.Exit(0)
 	}
 
-	if len(media) > 0 {
-		res := struct {
-			MediaID          int64  `json:"media_id"`
-			MediaIDString    string `json:"media_id_string"`
-			Size             int    `json:"size"`
-			ExpiresAfterSecs int    `json:"expires_after_secs"`
-			Image            struct {
-				ImageType string `json:"image_type"`
-				W         int    `json:"w"`
-				H         int    `json:"h"`
-			} `json:"image"`
-		}{}
-		for i := range media {
-			err = upload(token, media[i], nil, &res)
-			if err != nil {
-				log.Fatal("cannot upload media:", err)
+	if embed != "" {
+		if asjson {
+			var res json.RawMessage
+			if err = oembed(embed, &res); err != nil {
+				log.Fatal("cannot get embeddable HTML:", err)
+			}
+			fmt.Println(string(res))
+		} else {
+			var res OEmbed
+			if err = oembed(embed, &res); err != nil {
+				log.Fatal("cannot get embeddable HTML:", err)
 			}
-			media[i] = res.MediaIDString
+			fmt.Print(res.HTML)
 		}
+		os.Exit(0)
 	}
 
-	if len(search) > 0 {
-		res := struct {
-			Statuses       []Tweet `json:"statuses"`
-			SearchMetadata `json:"search_metadata"`
-		}{}
-		opt := map[string]string{"q": search}
-		opt = countToOpt(map[string]string{"q": search}, count)
-		opt = sinceToOpt(opt, since)
-		opt = untilToOpt(opt, until)
-		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/search/tweets.json", opt, &res)
-		if err != nil {
-			log.Fatal("cannot get statuses:", err)
-		}
-		showTweets(res.Statuses, asjson, verbose)
-	} else if reply {
-		var tweets []Tweet
-		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/mentions_timeline.json", countToOpt(map[string]string{}, count), &tweets)
+	if draft != "" && draft != "post" {
+		store, err := loadDrafts(stateFile(file, profile, "drafts"))
 		if err != nil {
-			log.Fatal("cannot get tweets:", err)
+			log.Fatal("cannot load drafts:", err)
 		}
-		showTweets(tweets, asjson, verbose)
-	} else if list != "" {
-		part := strings.SplitN(list, "/", 2)
-		if len(part) == 1 {
-			var account Account
-			err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/account/settings.json", nil, &account)
+		switch draft {
+		case "save":
+			text := strings.Join(flag.Args(), " ")
+			if flag.NArg() == 0 {
+				text, err = editText(composeTemplate(config["Signature"], nil))
+				if err != nil {
+					log.Fatal("cannot compose a draft:", err)
+				}
+				text = stripComments(text)
+			}
+			if text == "" && len(media) == 0 {
+				log.Fatal("aborted: empty draft")
+			}
+			d := Draft{Text: text}
+			if inreply != "" {
+				if d.ReplyTo, err = parseTweetID(inreply); err != nil {
+					log.Fatal("cannot get tweet ID:", err)
+				}
+			}
+			for _, m := range media {
+				// media are stored with absolute paths to post from anywhere
+				if m, err = mediaPath(m); err != nil {
+					log.Fatal("cannot save draft:", err)
+				}
+				d.Media = append(d.Media, m)
+			}
+			fmt.Println("saved draft:", store.add(d))
+		case "list":
+			showDrafts(store.Drafts, asjson)
+			os.Exit(0)
+		case "edit":
+			d, err := store.find(flag.Arg(0))
 			if err != nil {
-				log.Fatal("cannot get account:", err)
+				log.Fatal("cannot edit draft:", err)
 			}
-			part = []string{account.ScreenName, part[0]}
+			text, err := editText(d.Text)
+			if err != nil {
+				log.Fatal("cannot edit draft:", err)
+			}
+			d.Text = strings.TrimSpace(text)
+			d.Updated = time.Now()
+			fmt.Println("updated draft:", d.ID)
+		case "delete":
+			d, err := store.find(flag.Arg(0))
+			if err != nil {
+				log.Fatal("cannot delete draft:", err)
+			}
+			store.remove(d.ID)
+			fmt.Println("deleted draft:", flag.Arg(0))
+		default:
+			log.Fatalf("unknown draft command %q: use save, list, edit, post or delete", draft)
 		}
-		var tweets []Tweet
-		opt := map[string]string{"owner_screen_name": part[0], "slug": part[1]}
-		opt = countToOpt(opt, count)
-		opt = sinceIDtoOpt(opt, sinceID)
-		opt = maxIDtoOpt(opt, maxID)
-		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/lists/statuses.json", opt, &tweets)
-		if err != nil {
-			log.Fatal("cannot get tweets:", err)
+		if err = store.save(); err != nil {
+			log.Fatal("cannot store drafts:", err)
 		}
-		showTweets(tweets, asjson, verbose)
-	} else if user != "" {
-		var tweets []Tweet
-		opt := map[string]string{"screen_name": user}
-		opt = countToOpt(opt, count)
-		opt = sinceIDtoOpt(opt, sinceID)
-		opt = maxIDtoOpt(opt, maxID)
-		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/user_timeline.json", opt, &tweets)
+		os.Exit(0)
+	}
+
+	if queue || (flush && dryRun) {
+		q, err := loadTweetQueue(stateFile(file, profile, "queue"))
 		if err != nil {
-			log.Fatal("cannot get tweets:", err)
+			log.Fatal("cannot load queue:", err)
 		}
-		showTweets(tweets, asjson, verbose)
-	} else if favorite != "" {
-		err := rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/favorites/create.json", map[string]string{"id": favorite}, nil)
-		if err != nil {
-			log.Fatal("cannot create favorite:", err)
+		if !queue {
+			showQueue(q.Tweets, asjson)
+			os.Exit(0)
 		}
-		color.Set(color.FgHiRed)
-		fmt.Print(_EmojiRedHeart)
-		color.Set(color.Reset)
-		fmt.Println("favorited")
-	} else if fromfile != "" {
-		text, err := readFile(fromfile)
-		if err != nil {
-			log.Fatal("cannot read a new tweet:", err)
+		queued := QueuedTweet{Text: withContentWarning(cw, strings.Join(flag.Args(), " ")), Queued: time.Now()}
+		if strings.TrimSpace(queued.Text) == "" && len(media) == 0 {
+			log.Fatal("aborted: empty tweet")
 		}
-		var tweet Tweet
-		err = rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/statuses/update.json", map[string]string{"status": string(text), "in_reply_to_status_id": inreply, "media_ids": media.String()}, &tweet)
-		if err != nil {
+		if n := weightedLength(queued.Text); n > _MaxTweetLength {
+			log.Fatalf("tweet is too long: %d/%d", n, _MaxTweetLength)
+		}
+		if inreply != "" {
+			if queued.ReplyTo, err = parseTweetID(inreply); err != nil {
+				log.Fatal("cannot get tweet ID:", err)
+			}
+		}
+		for _, m := range media {
+			if m, err = mediaPath(m); err != nil {
+				log.Fatal("cannot queue tweet:", err)
+			}
+			queued.Media = append(queued.Media, m)
+		}
+		q.Tweets = append(q.Tweets, queued)
+		if err = q.save(); err != nil {
+			log.Fatal("cannot store queue:", err)
+		}
+		fmt.Printf("queued: %d tweets in queue\n", len(q.Tweets))
+		os.Exit(0)
+	}
+
+	if scheduleAt != "" {
+		store, err := loadSchedule(stateFile(file, profile, "schedule"))
+		if err != nil {
+			log.Fatal("cannot load schedule:", err)
+		}
+		loc, err := scheduleLocation(config["TimeZone"])
+		if err != nil {
+			log.Fatal("invalid TimeZone:", err)
+		}
+		switch scheduleAt {
+		case "list":
+			showSchedule(store.Tweets, loc, asjson)
+			os.Exit(0)
+		case "cancel":
+			st, err := store.find(flag.Arg(0))
+			if err != nil {
+				log.Fatal("cannot cancel scheduled tweet:", err)
+			}
+			store.remove(st.ID)
+			fmt.Println("canceled scheduled tweet:", flag.Arg(0))
+		default:
+			now := time.Now()
+			at, err := parseScheduleTime(scheduleAt, loc, now)
+			if err != nil {
+				log.Fatal("cannot schedule tweet:", err)
+			}
+			if !at.After(now) {
+				log.Fatalf("cannot schedule tweet: %v is in the past", at.In(loc).Format("2006-01-02 15:04 MST"))
+			}
+			st := ScheduledTweet{Text: withContentWarning(cw, strings.Join(flag.Args(), " ")), At: at.UTC()}
+			if strings.TrimSpace(st.Text) == "" && len(media) == 0 {
+				log.Fatal("aborted: empty tweet")
+			}
+			if n := weightedLength(st.Text); n > _MaxTweetLength {
+				log.Fatalf("tweet is too long: %d/%d", n, _MaxTweetLength)
+			}
+			if inreply != "" {
+				if st.ReplyTo, err = parseTweetID(inreply); err != nil {
+					log.Fatal("cannot get tweet ID:", err)
+				}
+			}
+			for _, m := range media {
+				if m, err = mediaPath(m); err != nil {
+					log.Fatal("cannot schedule tweet:", err)
+				}
+				st.Media = append(st.Media, m)
+			}
+			id := store.add(st)
+			fmt.Printf("scheduled: %d at %s\n", id, at.In(loc).Format("2006-01-02 15:04 MST"))
+		}
+		if err = store.save(); err != nil {
+			log.Fatal("cannot store schedule:", err)
+		}
+		os.Exit(0)
+	}
+
+	if countChars {
+		text := strings.Join(flag.Args(), " ")
+		if flag.NArg() == 0 {
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				log.Fatal("cannot read text:", err)
+			}
+			text = strings.TrimRight(string(b), "\r\n")
+		}
+		n := weightedLength(text)
+		if n > _MaxTweetLength {
+			fmt.Printf("%d/%d (over by %d)\n", n, _MaxTweetLength, n-_MaxTweetLength)
+			os.Exit(1)
+		}
+		fmt.Printf("%d/%d (%d left)\n", n, _MaxTweetLength, _MaxTweetLength-n)
+		os.Exit(0)
+	}
+
+	if detectLang {
+		var text string
+		if compose {
+			text, err = editText(composeTemplate(config["Signature"], nil))
+			if err != nil {
+				log.Fatal("cannot compose a new tweet:", err)
+			}
+			text = stripComments(text)
+		} else if fromfile != "" {
+			b, err := readFile(fromfile)
+			if err != nil {
+				log.Fatal("cannot read a new tweet:", err)
+			}
+			text = string(b)
+		} else {
+			text = strings.Join(flag.Args(), " ")
+		}
+		lang := detectLanguage(text)
+		if command := config["LangDetectCommand"]; command != "" {
+			lang, err = detectLanguageCommand(command, text)
+			if err != nil {
+				log.Fatal("cannot detect language:", err)
+			}
+		}
+		fmt.Println(lang)
+		os.Exit(0)
+	}
+	if reauthorize {
+		// authorize always shows the approval screen unlike authenticate
+		oauthClient.ResourceOwnerAuthorizationURI = "https://api.twitter.com/oauth/authorize"
+		delete(config, "AccessToken")
+		delete(config, "AccessSecret")
+		delete(config, "ScreenName")
+	}
+	if accessToken == "" {
+		accessToken = os.Getenv("TWTY_ACCESS_TOKEN")
+	}
+	if accessSecret == "" {
+		accessSecret = os.Getenv("TWTY_ACCESS_SECRET")
+	}
+	if accessToken != "" && accessSecret != "" {
+		config["AccessToken"] = accessToken
+		config["AccessSecret"] = accessSecret
+	}
+	// bookmarks require OAuth 2.0 user context
+	token, authorized, err := getAccessToken(config, bookmark != "" || unbookmark != "" || bookmarks)
+	if err != nil {
+		log.Fatal("cannot get access token:", err)
+	}
+	if authorized || (saveToken && accessToken != "" && accessSecret != "") {
+		err = storeConfig(file, config)
+		if err != nil {
+			log.Fatal("cannot store file:", err)
+		}
+	}
+
+	var attachmentURL string
+	// tweet IDs can be given as URL of the tweets
+	for _, id := range []*string{&quote, &inreply, &favorite, &unfavorite, &bookmark, &unbookmark} {
+		if *id == "" {
+			continue
+		}
+		*id, err = parseTweetID(*id)
+		if err != nil {
+			log.Fatal("cannot get tweet ID:", err)
+		}
+	}
+	if quote != "" && api == "v2" {
+		// API v2 takes the ID of the quoted tweet
+		attachmentURL = tweetURL(Tweet{Identifier: quote})
+	} else if quote != "" {
+		var tweet Tweet
+		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/show.json", map[string]string{"id": quote}, &tweet)
+		if err != nil {
+			log.Fatal("cannot get tweet:", err)
+		}
+		if tweet.Identifier == "" {
+			log.Fatal("cannot get tweet:", quote)
+		}
+		attachmentURL = tweetURL(tweet)
+	}
+	// mentions are prepended to the reply with -reply-all
+	var mentions []string
+	updateOpt := func(text string) map[string]string {
+		opt := map[string]string{"status": prependMentions(mentions, withContentWarning(cw, text)), "in_reply_to_status_id": inreply, "media_ids": media.String()}
+		if attachmentURL != "" {
+			opt["attachment_url"] = attachmentURL
+		}
+		if replySettings != "" {
+			opt["reply_settings"] = replySettings
+		}
+		if lat != "" {
+			opt["lat"] = lat
+			opt["long"] = long
+			opt["display_coordinates"] = "true"
+		}
+		if place != "" {
+			opt["place_id"] = place
+		}
+		if sensitive {
+			opt["possibly_sensitive"] = "true"
+		}
+		if len(pollOptions) > 0 {
+			opt["poll_options"] = strings.Join(pollOptions, ",")
+			opt["poll_duration_minutes"] = strconv.Itoa(pollDuration)
+		}
+		return opt
+	}
+	// myScreenName returns screen name of the account, which is cached in
+	// the configuration file unless the access token is given with -token
+	ephemeral := accessToken != "" && accessSecret != "" && !saveToken
+	myScreenName := func() (string, error) {
+		if !ephemeral && config["ScreenName"] != "" {
+			return config["ScreenName"], nil
+		}
+		var account Account
+		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/account/settings.json", nil, &account)
+		if err != nil {
+			return "", err
+		}
+		if account.ScreenName == "" {
+			return "", fmt.Errorf("cannot get screen name")
+		}
+		if !ephemeral {
+			config["ScreenName"] = account.ScreenName
+			if _, err := os.Stat(file); err == nil {
+				storeConfig(file, map[string]string{"ScreenName": account.ScreenName})
+			}
+		}
+		return account.ScreenName, nil
+	}
+	// myUserID returns user ID of the account for API v2, which is cached
+	// in the configuration file as well as the screen name
+	myUserID := func() (string, error) {
+		if !ephemeral && config["UserID"] != "" {
+			return config["UserID"], nil
+		}
+		me, err := userV2(token, "")
+		if err != nil {
+			return "", err
+		}
+		if !ephemeral {
+			config["UserID"] = me.ID
+			config["ScreenName"] = me.Username
+			if _, err := os.Stat(file); err == nil {
+				storeConfig(file, map[string]string{"UserID": me.ID, "ScreenName": me.Username})
+			}
+		}
+		return me.ID, nil
+	}
+	getTweet = func(id string) (Tweet, error) {
+		if api == "v2" {
+			return lookupTweetV2(token, id)
+		}
+		var tweet Tweet
+		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/show.json", map[string]string{"id": id}, &tweet)
+		return tweet, err
+	}
+	if replyAll {
+		if inreply == "" {
+			log.Fatal("-reply-all requires -i")
+		}
+		// -i without text retweets the tweet
+		if flag.NArg() == 0 && !compose && !interactive && fromfile == "" && template == "" {
+			log.Fatal("-reply-all requires text of the reply")
+		}
+		original, err := getTweet(inreply)
+		if err != nil {
+			log.Fatal("cannot get tweet:", err)
+		}
+		me, err := myScreenName()
+		if err != nil {
+			log.Fatal("cannot get screen name:", err)
+		}
+		mentions = replyMentions(original, me)
+	}
+	// postTweet posts the tweet unless it is the same text as a recent one,
+	// which twitter rejects as a duplicate
+	postTweet := func(opt map[string]string, tweet *Tweet) error {
+		text := opt["status"]
+		history, err := loadPostHistory(stateFile(file, profile, "history"))
+		if err != nil {
+			return err
+		}
+		if dup := history.find(text); dup != nil && strings.TrimSpace(text) != "" {
+			fmt.Fprintf(os.Stderr, "you posted the same text at %s (%s), which twitter rejects as a duplicate\n",
+				dup.Posted.Local().Format("2006-01-02 15:04"), dup.ID)
+			if !yes && !confirm("Post anyway?") {
+				return fmt.Errorf("duplicate of tweet %s", dup.ID)
+			}
+		}
+		// polls and reply settings are available only with API v2
+		if api == "v2" || opt["poll_options"] != "" || opt["reply_settings"] != "" {
+			err = postTweetV2(token, opt, tweet)
+		} else {
+			err = rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/statuses/update.json", opt, tweet)
+		}
+		if err != nil || tweet.Identifier == "" {
+			return err
+		}
+		history.add(text, tweet.Identifier)
+		if err := history.save(); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: cannot store history of tweets:", err)
+		}
+		return nil
+	}
+	// postStored posts a tweet stored to post later, like queued or scheduled ones
+	postStored := func(text string, media []string, replyTo string) (Tweet, error) {
+		var tweet Tweet
+		opt := map[string]string{"status": text, "in_reply_to_status_id": replyTo}
+		if len(media) > 0 {
+			ids, err := uploadMedia(token, media, stateFile(file, profile, "media-cache"), !noWait)
+			if err != nil {
+				return tweet, err
+			}
+			opt["media_ids"] = strings.Join(ids, ",")
+		}
+		err := postTweet(opt, &tweet)
+		if err == nil && tweet.Identifier == "" {
+			err = fmt.Errorf("no tweet ID returned")
+		}
+		return tweet, err
+	}
+
+	// paginate fetches pages of the timeline for -count and -pages
+	paginate := func(opt map[string]string, pageSize int, fetch func(map[string]string) ([]Tweet, error)) ([]Tweet, error) {
+		n, _ := strconv.Atoi(count)
+		return fetchPages(opt, n, pageSize, pages, fetch)
+	}
+
+	// sinceSeen returns since_id for the timeline, which is the last seen ID with -new
+	sinceSeen := func(timeline string) int64 {
+		if newOnly && sinceID == 0 {
+			return seen.get(timeline)
+		}
+		return sinceID
+	}
+	renderTimeline := func(timeline string, tweets []Tweet) {
+		if newOnly {
+			seen.mark(timeline, tweets)
+			if err := seen.save(); err != nil {
+				log.Fatal("cannot store seen markers:", err)
+			}
+		}
+		renderTweets(tweets)
+	}
+
+	// tweeted shows ID and URL of the posted tweet. The screen name is
+	// fetched once for the URL if the response does not have it (ex: v2).
+	tweeted := func(tweet Tweet) {
+		fmt.Println("tweeted:", tweet.Identifier)
+		if tweet.User.ScreenName == "" && tweet.Identifier != "" {
+			if name, err := myScreenName(); err == nil {
+				tweet.User.ScreenName = name
+			}
+		}
+		u := tweetURL(tweet)
+		err := saveLastTweet(stateFile(file, profile, "last-tweet"), lastTweet{ID: tweet.Identifier, URL: u})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warning: cannot store last tweet:", err)
+		}
+		fmt.Println(u)
+		if copyURL {
+			if err := copyToClipboard(u); err != nil {
+				fmt.Fprintln(os.Stderr, "warning: cannot copy URL:", err)
+			}
+		}
+		if verify {
+			if err := verifyTweet(token, tweet.Identifier); err != nil {
+				fmt.Fprintln(os.Stderr, "warning: cannot verify tweet:", err)
+			}
+		}
+	}
+
+	// waitUndo shows the text and waits -delay before posting, so that the
+	// tweet can be canceled with Ctrl-C
+	waitUndo := func(text string) {
+		if postDelay <= 0 || dryRun {
+			return
+		}
+		if text != "" {
+			fmt.Println(text)
+		}
+		if !countdown(os.Stdout, postDelay) {
+			log.Fatal("aborted")
+		}
+	}
+
+	// postText posts the text, or a thread of it with -split. Only the first
+	// tweet of the thread has content warning, media and quote.
+	postText := func(text string) {
+		if !split {
+			waitUndo(withContentWarning(cw, text))
+			var tweet Tweet
+			err := postTweet(updateOpt(text), &tweet)
+			if err == errSkipped {
+				return
+			}
+			if err != nil {
+				log.Fatal("cannot post tweet:", err)
+			}
+			tweeted(tweet)
+			return
+		}
+		parts := splitThread(text, _MaxTweetLength-weightedLength(withContentWarning(cw, "")))
+		for i, part := range parts {
+			if i == 0 {
+				part = withContentWarning(cw, part)
+			}
+			fmt.Printf("--- %d/%d ---\n%s\n", i+1, len(parts), part)
+		}
+		if dryRun || (len(parts) > 1 && !confirm(fmt.Sprintf("Post %d tweets?", len(parts)))) {
+			return
+		}
+		waitUndo("")
+		var prev Tweet
+		for i, part := range parts {
+			opt := map[string]string{"status": part, "in_reply_to_status_id": prev.Identifier}
+			if i == 0 {
+				opt = updateOpt(part)
+			}
+			var tweet Tweet
+			err := postTweet(opt, &tweet)
+			if err != nil {
+				log.Fatalf("cannot post tweet %d/%d: %v", i+1, len(parts), err)
+			}
+			tweeted(tweet)
+			prev = tweet
+		}
+	}
+
+	if len(media) > 0 {
+		ids, err := uploadMedia(token, media, stateFile(file, profile, "media-cache"), !noWait)
+		if err != nil {
+			log.Fatal("cannot upload media:", err)
+		}
+		// posting without alt texts is refused not to lose them silently
+		if err = setAltTexts(token, ids, alts); err != nil {
+			log.Fatal("cannot set alt text:", err)
+		}
+		copy(media, ids)
+	}
+
+	if raw != "" {
+		method, uri, err := rawEndpoint(raw)
+		if err != nil {
+			log.Fatal("cannot call API:", err)
+		}
+		var res json.RawMessage
+		err = rawCall(token, method, uri, rawParams, &res)
+		if err == errSkipped {
+			return
+		}
+		if err != nil {
+			log.Fatal("cannot call API:", err)
+		}
+		fmt.Println(string(res))
+	} else if allAccounts {
+		names, err := profileNames(filepath.Dir(file))
+		if err != nil {
+			log.Fatal("cannot get profiles:", err)
+		}
+		var tweets []Tweet
+		for _, name := range names {
+			label := name
+			if label == "" {
+				label = "default"
+			}
+			_, config, err := getConfig(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skip profile %s: %v\n", label, err)
+				continue
+			}
+			if config["AccessToken"] == "" || config["AccessSecret"] == "" {
+				fmt.Fprintf(os.Stderr, "skip profile %s: not authorized\n", label)
+				continue
+			}
+			token, _, err := getAccessToken(config, false)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skip profile %s: %v\n", label, err)
+				continue
+			}
+			var timeline []Tweet
+			err = rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/home_timeline.json", countToOpt(map[string]string{}, count), &timeline)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skip profile %s: %v\n", label, err)
+				continue
+			}
+			for i := range timeline {
+				timeline[i].Profile = label
+			}
+			tweets = append(tweets, timeline...)
+		}
+		sortTweetsByTime(tweets)
+		renderTweets(tweets)
+	} else if len(search) > 0 && useV2 && config["BearerToken"] != "" {
+		var res ResponseV2
+		opt := map[string]string{
+			"query":        search,
+			"expansions":   "author_id",
+			"tweet.fields": "created_at,author_id,public_metrics",
+		}
+		if n, err := strconv.Atoi(count); err == nil && n >= 10 && n <= 100 {
+			opt["max_results"] = count
+		}
+		err := bearerCall(config["BearerToken"], "https://api.twitter.com/2/tweets/search/recent", opt, &res)
+		if err != nil {
+			log.Fatal("cannot get statuses:", err)
+		}
+		renderTweets(res.Tweets())
+	} else if len(search) > 0 && api == "v2" {
+		opt := map[string]string{"q": search}
+		if lang != "" {
+			opt["q"] = search + " lang:" + lang
+		}
+		tweets, err := paginate(opt, 100, func(opt map[string]string) ([]Tweet, error) {
+			return searchV2(token, opt)
+		})
+		if err != nil {
+			log.Fatal("cannot get statuses:", err)
+		}
+		renderTweets(tweets)
+	} else if len(search) > 0 {
+		opt := map[string]string{"q": search}
+		if lang != "" {
+			opt["lang"] = lang
+		}
+		opt = sinceToOpt(opt, since)
+		opt = untilToOpt(opt, until)
+		tweets, err := paginate(opt, 100, func(opt map[string]string) ([]Tweet, error) {
+			res := struct {
+				Statuses       []Tweet `json:"statuses"`
+				SearchMetadata `json:"search_metadata"`
+			}{}
+			err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/search/tweets.json", opt, &res)
+			if err == nil && showElapsed {
+				fmt.Fprintf(os.Stderr, "search completed in %gs\n", res.CompletedIn)
+			}
+			return res.Statuses, err
+		})
+		if err != nil {
+			log.Fatal("cannot get statuses:", err)
+		}
+		renderTweets(tweets)
+	} else if reply {
+		opt := sinceIDtoOpt(map[string]string{}, sinceSeen("replies"))
+		var tweets []Tweet
+		var err error
+		if api == "v2" {
+			var userID string
+			if userID, err = myUserID(); err == nil {
+				tweets, err = paginate(opt, 100, func(opt map[string]string) ([]Tweet, error) {
+					return mentionsV2(token, userID, opt)
+				})
+			}
+		} else {
+			tweets, err = paginate(opt, 200, func(opt map[string]string) (tweets []Tweet, err error) {
+				err = rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/mentions_timeline.json", opt, &tweets)
+				return
+			})
+		}
+		if err != nil {
+			log.Fatal("cannot get tweets:", err)
+		}
+		renderTimeline("replies", tweets)
+	} else if listCreate != "" || listUpdate != "" {
+		mode, err := listMode(private, public)
+		if err != nil {
+			log.Fatal(err)
+		}
+		uri, opt, action := "https://api.twitter.com/1.1/lists/create.json", map[string]string{"name": listCreate}, "create"
+		if listUpdate != "" {
+			screenName, err := myScreenName()
+			if err != nil {
+				log.Fatal("cannot get account:", err)
+			}
+			uri, opt, action = "https://api.twitter.com/1.1/lists/update.json", listParams(listUpdate, screenName), "update"
+			if listName != "" {
+				opt["name"] = listName
+			}
+			if mode == "" && listName == "" && listDescription == "" {
+				log.Fatal("nothing to update: specify -list-name, -list-description, -private or -public")
+			}
+		}
+		if mode != "" {
+			opt["mode"] = mode
+		}
+		if listDescription != "" {
+			opt["description"] = listDescription
+		}
+		var res List
+		err = rawCall(token, http.MethodPost, uri, opt, &res)
+		if err == errSkipped {
+			return
+		}
+		if err != nil {
+			log.Fatal("cannot "+action+" list:", err)
+		}
+		if !asjson {
+			fmt.Println(action+"d:", res.FullName)
+		}
+		showList(res, asjson)
+	} else if listDestroy != "" {
+		screenName, err := myScreenName()
+		if err != nil {
+			log.Fatal("cannot get account:", err)
+		}
+		opt := listParams(listDestroy, screenName)
+		var res List
+		err = rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/lists/show.json", opt, &res)
+		if err != nil {
+			log.Fatal("cannot get list:", err)
+		}
+		showList(res, false)
+		if !yes && !dryRun && !confirm("Delete this list?") {
+			log.Fatal("aborted")
+		}
+		err = rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/lists/destroy.json", map[string]string{"list_id": res.IDStr}, &res)
+		if err == errSkipped {
+			return
+		}
+		if err != nil {
+			log.Fatal("cannot delete list:", err)
+		}
+		fmt.Println("deleted:", res.FullName)
+	} else if list != "" {
+		part := strings.SplitN(list, "/", 2)
+		if len(part) == 1 {
+			screenName, err := myScreenName()
+			if err != nil {
+				log.Fatal("cannot get account:", err)
+			}
+			part = []string{screenName, part[0]}
+		}
+		timeline := "list:" + part[0] + "/" + part[1]
+		opt := map[string]string{"owner_screen_name": part[0], "slug": part[1]}
+		opt = sinceIDtoOpt(opt, sinceSeen(timeline))
+		opt = maxIDtoOpt(opt, maxID)
+		tweets, err := paginate(opt, 200, func(opt map[string]string) (tweets []Tweet, err error) {
+			err = rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/lists/statuses.json", opt, &tweets)
+			return
+		})
+		if err != nil {
+			log.Fatal("cannot get tweets:", err)
+		}
+		renderTimeline(timeline, tweets)
+	} else if user != "" {
+		timeline := "user:" + user
+		opt := map[string]string{"screen_name": user}
+		if noReplies {
+			opt["exclude_replies"] = "true"
+		}
+		if noRTs {
+			opt["include_rts"] = "false"
+		}
+		opt = sinceIDtoOpt(opt, sinceSeen(timeline))
+		opt = maxIDtoOpt(opt, maxID)
+		var tweets []Tweet
+		var err error
+		if api == "v2" {
+			var u UserV2
+			if u, err = userV2(token, user); err == nil {
+				tweets, err = paginate(opt, 100, func(opt map[string]string) ([]Tweet, error) {
+					return userTweetsV2(token, u.ID, opt)
+				})
+			}
+		} else {
+			tweets, err = paginate(opt, 200, func(opt map[string]string) (tweets []Tweet, err error) {
+				err = rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/user_timeline.json", opt, &tweets)
+				return
+			})
+		}
+		if err != nil {
+			log.Fatal("cannot get tweets:", err)
+		}
+		renderTimeline(timeline, tweets)
+	} else if status != "" {
+		id, err := parseTweetID(status)
+		if err != nil {
+			log.Fatal("cannot get tweet:", err)
+		}
+		tweet, err := getTweet(id)
+		if err != nil {
+			log.Fatal("cannot get tweet:", err)
+		}
+		showStatus(tweet, asjson)
+	} else if thread != "" {
+		id, err := parseTweetID(thread)
+		if err != nil {
+			log.Fatal("cannot get thread:", err)
+		}
+		tweets, err := fetchThread(token, id)
+		if err != nil {
+			log.Fatal("cannot get thread:", err)
+		}
+		renderTweets(tweets)
+	} else if quotes != "" {
+		id, err := parseTweetID(quotes)
+		if err != nil {
+			log.Fatal("cannot get quote tweets:", err)
+		}
+		var tweets []Tweet
+		if api == "v2" {
+			tweets, err = quoteTweetsV2(token, id, countToOpt(map[string]string{}, count))
+		} else {
+			tweets, err = searchQuoteTweets(token, id, count)
+		}
+		if err != nil {
+			log.Fatal("cannot get quote tweets:", err)
+		}
+		renderTweets(tweets)
+	} else if deleteID != "" {
+		ids := []string{deleteID}
+		if deleteID == "-" {
+			if !yes {
+				log.Fatal("cannot delete tweets: -y is required to read IDs from STDIN")
+			}
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				log.Fatal("cannot read IDs:", err)
+			}
+			ids = strings.Fields(string(b))
+		}
+		failed := 0
+		for _, s := range ids {
+			id, err := parseTweetID(s)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "cannot delete tweet:", err)
+				failed++
+				continue
+			}
+			if !yes {
+				tweet, err := getTweet(id)
+				if err != nil {
+					log.Fatal("cannot get tweet:", err)
+				}
+				showTweets([]Tweet{tweet}, false, false)
+				if !confirm("Delete this tweet?") {
+					log.Fatal("aborted")
+				}
+			}
+			var tweet Tweet
+			if api == "v2" {
+				err = deleteTweetV2(token, id, &tweet)
+			} else {
+				err = rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/statuses/destroy/"+id+".json", nil, &tweet)
+			}
+			if err == errSkipped {
+				continue
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "cannot delete tweet %s: %v\n", id, err)
+				failed++
+				continue
+			}
+			fmt.Println("deleted:", id, html.UnescapeString(replacer.Replace(tweet.Text)))
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	} else if likes != "" {
+		opt := map[string]string{"screen_name": likes}
+		opt = sinceIDtoOpt(opt, sinceID)
+		opt = maxIDtoOpt(opt, maxID)
+		var tweets []Tweet
+		var err error
+		if api == "v2" {
+			var u UserV2
+			if u, err = userV2(token, likes); err == nil {
+				tweets, err = likedTweetsV2(token, u.ID, countToOpt(opt, count))
+			}
+		} else {
+			tweets, err = paginate(opt, 200, func(opt map[string]string) (tweets []Tweet, err error) {
+				err = rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/favorites/list.json", opt, &tweets)
+				return
+			})
+		}
+		if err != nil {
+			log.Fatal("cannot get tweets:", err)
+		}
+		renderTweets(tweets)
+	} else if bookmark != "" || unbookmark != "" || bookmarks {
+		bearer := config["OAuth2AccessToken"]
+		userID, err := bearerUserIDV2(bearer)
+		if err != nil {
+			log.Fatal("cannot get account:", err)
+		}
+		if bookmarks {
+			n, err := strconv.Atoi(count)
+			if err != nil || n <= 0 {
+				n = 20
+			}
+			tweets, err := bookmarksV2(bearer, userID, n)
+			if err != nil {
+				log.Fatal("cannot get bookmarks:", err)
+			}
+			renderTweets(tweets)
+		} else if bookmark != "" {
+			if err = bookmarkV2(bearer, userID, bookmark, false); err == errSkipped {
+				return
+			} else if err != nil {
+				log.Fatal("cannot add bookmark:", err)
+			}
+			fmt.Println("bookmarked:", bookmark)
+		} else {
+			if err = bookmarkV2(bearer, userID, unbookmark, true); err == errSkipped {
+				return
+			} else if err != nil {
+				log.Fatal("cannot remove bookmark:", err)
+			}
+			fmt.Println("unbookmarked:", unbookmark)
+		}
+	} else if favorite != "" {
+		var err error
+		if api == "v2" {
+			var userID string
+			if userID, err = myUserID(); err == nil {
+				err = likeV2(token, userID, favorite)
+			}
+		} else {
+			err = rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/favorites/create.json", map[string]string{"id": favorite}, nil)
+		}
+		if err == errSkipped {
+			return
+		}
+		if err != nil {
+			log.Fatal("cannot create favorite:", err)
+		}
+		color.Set(color.FgHiRed)
+		fmt.Print(_EmojiRedHeart)
+		color.Set(color.Reset)
+		fmt.Println("favorited")
+	} else if unfavorite != "" {
+		var err error
+		if api == "v2" {
+			var userID string
+			if userID, err = myUserID(); err == nil {
+				err = unlikeV2(token, userID, unfavorite)
+			}
+		} else {
+			err = rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/favorites/destroy.json", map[string]string{"id": unfavorite}, nil)
+		}
+		if err == errSkipped {
+			return
+		}
+		if err != nil {
+			log.Fatal("cannot destroy favorite:", err)
+		}
+		color.Set(color.FgHiRed)
+		fmt.Print(_EmojiRedHeart)
+		color.Set(color.Reset)
+		fmt.Println("unfavorited")
+	} else if flush {
+		q, err := loadTweetQueue(stateFile(file, profile, "queue"))
+		if err != nil {
+			log.Fatal("cannot load queue:", err)
+		}
+		n := len(q.Tweets)
+		posted, err := q.flush(func(queued QueuedTweet) (Tweet, error) {
+			return postStored(queued.Text, queued.Media, queued.ReplyTo)
+		})
+		if err != nil {
+			log.Fatal("cannot store queue:", err)
+		}
+		if dryRun {
+			return
+		}
+		fmt.Printf("posted %d of %d queued tweets\n", posted, n)
+		if posted < n {
+			os.Exit(1)
+		}
+	} else if runScheduler || scheduler {
+		// postDue posts scheduled tweets whose time has arrived, and returns
+		// the numbers of posted and due tweets
+		postDue := func() (int, int) {
+			store, err := loadSchedule(stateFile(file, profile, "schedule"))
+			if err != nil {
+				log.Fatal("cannot load schedule:", err)
+			}
+			due := store.due(time.Now())
+			posted := 0
+			for _, st := range due {
+				tweet, err := postStored(st.Text, st.Media, st.ReplyTo)
+				if err == errSkipped {
+					continue
+				}
+				// load again not to lose tweets scheduled while posting
+				store, serr := loadSchedule(stateFile(file, profile, "schedule"))
+				if serr != nil {
+					log.Fatal("cannot load schedule:", serr)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "cannot post scheduled tweet %d: %v\n", st.ID, err)
+					if failed, ferr := store.find(strconv.Itoa(st.ID)); ferr == nil {
+						failed.Attempts++
+						failed.Error = err.Error()
+					}
+				} else {
+					fmt.Println("tweeted:", tweet.Identifier)
+					store.remove(st.ID)
+					posted++
+				}
+				if err = store.save(); err != nil {
+					log.Fatal("cannot store schedule:", err)
+				}
+			}
+			return posted, len(due)
+		}
+		if scheduler {
+			fmt.Fprintf(os.Stderr, "scheduler started, checking scheduled tweets every %v\n", _SchedulerInterval)
+			for {
+				postDue()
+				time.Sleep(_SchedulerInterval)
+			}
+		}
+		posted, n := postDue()
+		if dryRun {
+			return
+		}
+		fmt.Printf("posted %d of %d scheduled tweets\n", posted, n)
+		if posted < n {
+			os.Exit(1)
+		}
+	} else if draft == "post" {
+		store, err := loadDrafts(stateFile(file, profile, "drafts"))
+		if err != nil {
+			log.Fatal("cannot load drafts:", err)
+		}
+		d, err := store.find(flag.Arg(0))
+		if err != nil {
+			log.Fatal("cannot post draft:", err)
+		}
+		opt := map[string]string{"status": withContentWarning(cw, d.Text), "in_reply_to_status_id": d.ReplyTo}
+		if len(d.Media) > 0 {
+			ids, err := uploadMedia(token, d.Media, stateFile(file, profile, "media-cache"), !noWait)
+			if err != nil {
+				log.Fatal("cannot upload media:", err)
+			}
+			opt["media_ids"] = strings.Join(ids, ",")
+		}
+		waitUndo(opt["status"])
+		var tweet Tweet
+		err = postTweet(opt, &tweet)
+		if err == errSkipped {
+			return
+		}
+		if err != nil {
 			log.Fatal("cannot post tweet:", err)
 		}
-		fmt.Println("tweeted:", tweet.Identifier)
-	} else if show_user != "" {
-		var user User
+		tweeted(tweet)
+		store.remove(d.ID)
+		if err = store.save(); err != nil {
+			log.Fatal("cannot store drafts:", err)
+		}
+	} else if interactive {
+		text, err := composeInteractive(os.Stdin, os.Stdout, func(text string) string {
+			return withContentWarning(cw, text)
+		})
+		if err != nil {
+			log.Fatal("cannot compose a new tweet:", err)
+		}
+		waitUndo("")
+		var tweet Tweet
+		err = postTweet(updateOpt(text), &tweet)
+		if err == errSkipped {
+			return
+		}
+		if err != nil {
+			log.Fatal("cannot post tweet:", err)
+		}
+		tweeted(tweet)
+	} else if compose {
+		var original *Tweet
+		if inreply != "" {
+			tweet, err := getTweet(inreply)
+			if err != nil {
+				log.Fatal("cannot get tweet:", err)
+			}
+			original = &tweet
+		}
+		text, err := editText(composeTemplate(config["Signature"], original))
+		if err != nil {
+			log.Fatal("cannot compose a new tweet:", err)
+		}
+		text = stripComments(text)
+		if text == "" {
+			log.Fatal("aborted: empty tweet")
+		}
+		if n := weightedLength(withContentWarning(cw, text)); n > _MaxTweetLength {
+			log.Fatalf("tweet is too long: %d/%d", n, _MaxTweetLength)
+		}
+		fmt.Println(withContentWarning(cw, text))
+		if !confirm("Post this tweet?") {
+			log.Fatal("aborted")
+		}
+		waitUndo("")
+		var tweet Tweet
+		err = postTweet(updateOpt(text), &tweet)
+		if err == errSkipped {
+			return
+		}
+		if err != nil {
+			log.Fatal("cannot post tweet:", err)
+		}
+		tweeted(tweet)
+	} else if stdinJSON {
+		requests, err := readTweetRequests(os.Stdin)
+		if err != nil {
+			log.Fatal("cannot read tweets:", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, request := range requests {
+			if dryRun {
+				enc.Encode(request)
+				continue
+			}
+			opt := map[string]string{"status": request.Text, "in_reply_to_status_id": request.ReplyTo}
+			if len(request.Media) > 0 {
+				ids, err := uploadMedia(token, request.Media, stateFile(file, profile, "media-cache"), !noWait)
+				if err != nil {
+					log.Fatal("cannot upload media:", err)
+				}
+				opt["media_ids"] = strings.Join(ids, ",")
+			}
+			var tweet Tweet
+			err = postTweet(opt, &tweet)
+			if err != nil {
+				log.Fatal("cannot post tweet:", err)
+			}
+			if tweet.Identifier == "" {
+				log.Fatal("cannot post tweet:", request.Text)
+			}
+			enc.Encode(map[string]string{"id_str": tweet.Identifier})
+		}
+	} else if template != "" {
+		tmpl, ok := config["Template."+template]
+		if !ok {
+			log.Fatalf("cannot find template %q in configuration file", template)
+		}
+		text, err := expandTemplate(tmpl, config, time.Now())
+		if err != nil {
+			log.Fatal("cannot expand template:", err)
+		}
+		if n := weightedLength(withContentWarning(cw, text)); n > _MaxTweetLength {
+			log.Fatalf("tweet is too long: %d/%d", n, _MaxTweetLength)
+		}
+		if dryRun {
+			fmt.Println(withContentWarning(cw, text))
+			return
+		}
+		waitUndo(withContentWarning(cw, text))
+		var tweet Tweet
+		err = postTweet(updateOpt(text), &tweet)
+		if err == errSkipped {
+			return
+		}
+		if err != nil {
+			log.Fatal("cannot post tweet:", err)
+		}
+		tweeted(tweet)
+	} else if fromfile != "" {
+		text, err := readFile(fromfile)
+		if err != nil {
+			log.Fatal("cannot read a new tweet:", err)
+		}
+		postText(trimText(string(text), trim))
+	} else if showUserID != "" && api == "v2" {
+		u, err := userV2(token, "id:"+showUserID)
+		if err != nil {
+			log.Fatal("cannot get user:", err)
+		}
+		if asjson {
+			json.NewEncoder(os.Stdout).Encode(u)
+			return
+		}
+		showUser(u.User(), asjson, verbose)
+	} else if show_user != "" && api == "v2" {
+		u, err := userV2(token, show_user)
+		if err != nil {
+			log.Fatal("cannot get user:", err)
+		}
+		if asjson {
+			json.NewEncoder(os.Stdout).Encode(u)
+			return
+		}
+		showUser(u.User(), asjson, verbose)
+	} else if show_user != "" || showUserID != "" {
+		var raw json.RawMessage
 		screen_name := show_user
 		opt := map[string]string{"screen_name": screen_name}
-		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/users/show.json", opt, &user)
+		if showUserID != "" {
+			// renamed users can be found with their IDs
+			opt = map[string]string{"user_id": showUserID}
+		}
+		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/users/show.json", opt, &raw)
+		if err != nil {
+			log.Fatal("cannot get user:", err)
+		}
+		if asjson {
+			if err = writeRawJSON(os.Stdout, raw); err != nil {
+				log.Fatal("cannot show user:", err)
+			}
+			return
+		}
+		var user User
+		err = json.Unmarshal(raw, &user)
 		if err != nil {
 			log.Fatal("cannot get user:", err)
 		}
 		showUser(user, asjson, verbose)
+	} else if placeSearch != "" {
+		var res struct {
+			Result struct {
+				Places []Place `json:"places"`
+			} `json:"result"`
+		}
+		opt := map[string]string{"query": placeSearch, "lat": lat, "long": long}
+		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/geo/search.json", opt, &res)
+		if err != nil {
+			log.Fatal("cannot search places:", err)
+		}
+		showPlaces(res.Result.Places, asjson)
 	} else if search_user != "" {
 		var users []User
 		query := search_user
@@ -714,10 +4035,317 @@ func main() {
 			log.Fatal("cannot search users:", err)
 		}
 		showUsers(users, asjson, verbose)
-	} else if flag.NArg() == 0 && len(media) == 0 {
-		if inreply != "" {
+	} else if friendship != "" {
+		res := struct {
+			Relationship Relationship `json:"relationship"`
+		}{}
+		opt := map[string]string{"target_screen_name": friendship}
+		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/friendships/show.json", opt, &res)
+		if err != nil {
+			log.Fatal("cannot get friendship:", err)
+		}
+		showFriendship(res.Relationship, asjson)
+	} else if relationship != "" {
+		if flag.NArg() != 1 {
+			log.Fatal("-relationship needs two users: -relationship USER1 USER2")
+		}
+		res := struct {
+			Relationship Relationship `json:"relationship"`
+		}{}
+		opt := map[string]string{
+			"source_screen_name": strings.TrimPrefix(relationship, "@"),
+			"target_screen_name": strings.TrimPrefix(flag.Arg(0), "@"),
+		}
+		err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/friendships/show.json", opt, &res)
+		if err != nil {
+			log.Fatal("cannot get relationship:", err)
+		}
+		showRelationship(res.Relationship, asjson)
+	} else if lookup || lookupFile != "" {
+		var names []string
+		if lookupFile != "" {
+			list, err := readUserList(lookupFile)
+			if err != nil {
+				log.Fatal("cannot read users:", err)
+			}
+			names = list
+		}
+		if lookup {
+			if flag.NArg() > 0 {
+				names = append(names, parseUserList(flag.Args())...)
+			} else if lookupFile == "" {
+				list, err := readUserList("-")
+				if err != nil {
+					log.Fatal("cannot read users:", err)
+				}
+				names = list
+			}
+		}
+		users, err := lookupUserList(token, names)
+		if err != nil {
+			log.Fatal("cannot get users:", err)
+		}
+		if len(users) < len(names) {
+			fmt.Fprintf(os.Stderr, "warning: %d of %d users are not found\n", len(names)-len(users), len(names))
+		}
+		showUsers(users, asjson, verbose)
+	} else if mutuals != "" {
+		if flag.NArg() != 1 {
+			log.Fatal("-mutuals needs two users: -mutuals USER1 USER2")
+		}
+		var sets [2][]int64
+		for i, screenName := range []string{mutuals, flag.Arg(0)} {
+			opt := map[string]string{"screen_name": strings.TrimPrefix(screenName, "@"), "count": "5000", "stringify_ids": "false"}
+			ids, err := cursorIDs(token, "https://api.twitter.com/1.1/followers/ids.json", opt, 0)
+			if err != nil {
+				log.Fatal("cannot get followers:", err)
+			}
+			sets[i] = ids
+		}
+		ids := intersectIDs(sets[0], sets[1])
+		if limit > 0 && len(ids) > limit {
+			ids = ids[:limit]
+		}
+		users, err := lookupUsers(token, ids)
+		if err != nil {
+			log.Fatal("cannot get users:", err)
+		}
+		showUsers(users, asjson, verbose)
+	} else if blocked || muted {
+		uri := "https://api.twitter.com/1.1/blocks/list.json"
+		if muted {
+			uri = "https://api.twitter.com/1.1/mutes/users/list.json"
+		}
+		users, err := cursorUsers(token, uri, countToOpt(map[string]string{}, count), limit)
+		if err != nil {
+			log.Fatal("cannot get users:", err)
+		}
+		showUsers(users, asjson, verbose)
+	} else if followRequests {
+		ids, err := cursorIDs(token, "https://api.twitter.com/1.1/friendships/incoming.json", map[string]string{"stringify_ids": "false"}, limit)
+		if err != nil {
+			log.Fatal("cannot get follow requests:", err)
+		}
+		users, err := lookupUsers(token, ids)
+		if err != nil {
+			log.Fatal("cannot get users:", err)
+		}
+		showUsers(users, asjson, verbose)
+	} else if followers != "" || following != "" {
+		uri, screenName := "https://api.twitter.com/1.1/followers/list.json", followers
+		if following != "" {
+			uri, screenName = "https://api.twitter.com/1.1/friends/list.json", following
+		}
+		opt := map[string]string{"count": "200", "skip_status": "true"}
+		if screenName != "me" {
+			opt["screen_name"] = strings.TrimPrefix(screenName, "@")
+		}
+		total := limit
+		if n, err := strconv.Atoi(count); err == nil && n > 0 {
+			total = n
+		}
+		users, err := cursorUsers(token, uri, opt, total)
+		if err != nil {
+			log.Fatal("cannot get users:", err)
+		}
+		showUsers(users, asjson, verbose)
+	} else if len(userActions) > 0 {
+		if len(userActions) > 1 {
+			log.Fatalf("cannot use -%s with -%s", userActions[0].name, userActions[1].name)
+		}
+		action := userActions[0]
+		names, err := screenNames(action.users)
+		if err != nil {
+			log.Fatal("cannot read users:", err)
+		}
+		var showRelationship func(User)
+		if action.name == "follow" || action.name == "unfollow" {
+			showRelationship = func(user User) {
+				res := struct {
+					Relationship Relationship `json:"relationship"`
+				}{}
+				opt := map[string]string{"target_screen_name": user.ScreenName}
+				err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/friendships/show.json", opt, &res)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "cannot get friendship:", err)
+					return
+				}
+				showFriendship(res.Relationship, asjson)
+			}
+		}
+		errs := actOnUsers(token, action, names, interval, showRelationship)
+		if result != "" && !dryRun {
+			if err := writeUserResults(result, action, names, errs); err != nil {
+				log.Fatal("cannot write result:", err)
+			}
+		}
+		for _, err := range errs {
+			if err != nil {
+				os.Exit(1)
+			}
+		}
+	} else if snapshotFollowers {
+		snapshots, err := loadFollowerSnapshot(stateFile(file, profile, "followers"))
+		if err != nil {
+			log.Fatal("cannot load snapshot:", err)
+		}
+		opt := map[string]string{"count": "5000", "stringify_ids": "false"}
+		ids, err := cursorIDs(token, "https://api.twitter.com/1.1/followers/ids.json", opt, 0)
+		if err != nil {
+			log.Fatal("cannot get followers:", err)
+		}
+		var previous *snapshot
+		if last := snapshots.last(); last != nil {
+			s := *last
+			previous = &s
+		}
+		followedIDs, unfollowedIDs := snapshots.take(ids, time.Now())
+		// new followers and unfollowers are looked up at once
+		users, err := lookupUsers(token, append(append([]int64{}, followedIDs...), unfollowedIDs...))
+		if err != nil {
+			log.Fatal("cannot get users:", err)
+		}
+		found := make(map[int64]User)
+		for _, user := range users {
+			found[int64(user.Id)] = user
+		}
+		var missing []int64
+		usersOf := func(ids []int64) []User {
+			var users []User
+			for _, id := range ids {
+				if user, ok := found[id]; ok {
+					users = append(users, user)
+				} else {
+					missing = append(missing, id)
+				}
+			}
+			return users
+		}
+		followed, unfollowed := usersOf(followedIDs), usersOf(unfollowedIDs)
+		if err = snapshots.save(); err != nil {
+			log.Fatal("cannot save snapshot:", err)
+		}
+		showFollowerChanges(previous, *snapshots.last(), followed, unfollowed, missing, asjson)
+	} else if unfollowNonmutual {
+		progress, err := loadUnfollowProgress(stateFile(file, profile, "unfollow"))
+		if err != nil {
+			log.Fatal("cannot load progress:", err)
+		}
+		if len(progress.Pending) > 0 {
+			fmt.Printf("resuming to unfollow %d users started at %s\n", len(progress.Pending), progress.Started.Local().Format("2006-01-02 15:04"))
+		} else {
+			opt := map[string]string{"count": "5000", "stringify_ids": "false"}
+			friends, err := cursorIDs(token, "https://api.twitter.com/1.1/friends/ids.json", opt, 0)
+			if err != nil {
+				log.Fatal("cannot get friends:", err)
+			}
+			opt = map[string]string{"count": "5000", "stringify_ids": "false"}
+			followers, err := cursorIDs(token, "https://api.twitter.com/1.1/followers/ids.json", opt, 0)
+			if err != nil {
+				log.Fatal("cannot get followers:", err)
+			}
+			progress.Started, progress.Pending = time.Now(), subtractIDs(friends, followers)
+			if limit > 0 && len(progress.Pending) > limit {
+				progress.Pending = progress.Pending[:limit]
+			}
+		}
+		if len(progress.Pending) == 0 {
+			fmt.Println("no users to unfollow")
+			return
+		}
+		users, err := lookupUsers(token, progress.Pending)
+		if err != nil {
+			log.Fatal("cannot get users:", err)
+		}
+		showUsers(users, false, false)
+		if dryRun || !yes && !confirm(fmt.Sprintf("Unfollow %d users?", len(progress.Pending))) {
+			return
+		}
+		if err = progress.save(); err != nil {
+			log.Fatal("cannot save progress:", err)
+		}
+		names := make([]string, len(progress.Pending))
+		for i, id := range progress.Pending {
+			names[i] = "id:" + strconv.FormatInt(id, 10)
+		}
+		action := userAction{name: "unfollow", uri: "https://api.twitter.com/1.1/friendships/destroy.json", done: "unfollowed"}
+		errs := actOnUsers(token, action, names, interval, func(user User) {
+			progress.done(int64(user.Id))
+			if err := progress.save(); err != nil {
+				fmt.Fprintln(os.Stderr, "cannot save progress:", err)
+			}
+		})
+		if len(progress.Pending) == 0 {
+			if err = progress.remove(); err != nil {
+				log.Fatal("cannot remove progress:", err)
+			}
+		} else {
+			// failed users are retried when resumed
+			if err = progress.save(); err != nil {
+				log.Fatal("cannot save progress:", err)
+			}
+			fmt.Fprintf(os.Stderr, "warning: %d users are left to unfollow: run -unfollow-nonmutual again to resume\n", len(progress.Pending))
+		}
+		if result != "" {
+			if err := writeUserResults(result, action, names, errs); err != nil {
+				log.Fatal("cannot write result:", err)
+			}
+		}
+	} else if followBack {
+		opt := map[string]string{"count": "200", "skip_status": "true"}
+		followers, err := cursorUsers(token, "https://api.twitter.com/1.1/followers/list.json", opt, 0)
+		if err != nil {
+			log.Fatal("cannot get followers:", err)
+		}
+		friends, err := cursorUsers(token, "https://api.twitter.com/1.1/friends/list.json", opt, 0)
+		if err != nil {
+			log.Fatal("cannot get friends:", err)
+		}
+		users := notFollowedBack(followers, friends)
+		if limit > 0 && len(users) > limit {
+			users = users[:limit]
+		}
+		if len(users) == 0 {
+			fmt.Println("no users to follow back")
+			return
+		}
+		showUsers(users, false, false)
+		if dryRun || !yes && !confirm(fmt.Sprintf("Follow %d users?", len(users))) {
+			return
+		}
+		names := make([]string, len(users))
+		for i, user := range users {
+			names[i] = "id:" + strconv.Itoa(user.Id)
+		}
+		action := userAction{name: "follow", uri: "https://api.twitter.com/1.1/friendships/create.json", done: "followed"}
+		errs := actOnUsers(token, action, names, interval, nil)
+		if result != "" {
+			if err := writeUserResults(result, action, names, errs); err != nil {
+				log.Fatal("cannot write result:", err)
+			}
+		}
+	} else if flag.NArg() == 0 && len(media) == 0 && quote == "" {
+		if inreply != "" && api == "v2" {
+			userID, err := myUserID()
+			if err == nil {
+				err = retweetV2(token, userID, inreply)
+			}
+			if err == errSkipped {
+				return
+			}
+			if err != nil {
+				log.Fatal("cannot retweet:", err)
+			}
+			color.Set(color.FgHiYellow)
+			fmt.Print(_EmojiHighVoltage)
+			color.Set(color.Reset)
+			fmt.Println("retweeted:", inreply)
+		} else if inreply != "" {
 			var tweet Tweet
 			err := rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/statuses/retweet/"+inreply+".json", countToOpt(map[string]string{}, count), &tweet)
+			if err == errSkipped {
+				return
+			}
 			if err != nil {
 				log.Fatal("cannot retweet:", err)
 			}
@@ -726,19 +4354,28 @@ func main() {
 			color.Set(color.Reset)
 			fmt.Println("retweeted:", tweet.Identifier)
 		} else {
+			opt := sinceIDtoOpt(map[string]string{}, sinceSeen("home"))
 			var tweets []Tweet
-			err := rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/home_timeline.json", countToOpt(map[string]string{}, count), &tweets)
+			var err error
+			if api == "v2" {
+				var userID string
+				if userID, err = myUserID(); err == nil {
+					tweets, err = paginate(opt, 100, func(opt map[string]string) ([]Tweet, error) {
+						return homeTimelineV2(token, userID, opt)
+					})
+				}
+			} else {
+				tweets, err = paginate(opt, 200, func(opt map[string]string) (tweets []Tweet, err error) {
+					err = rawCall(token, http.MethodGet, "https://api.twitter.com/1.1/statuses/home_timeline.json", opt, &tweets)
+					return
+				})
+			}
 			if err != nil {
 				log.Fatal("cannot get tweets:", err)
 			}
-			showTweets(tweets, asjson, verbose)
+			renderTimeline("home", tweets)
 		}
 	} else {
-		var tweet Tweet
-		err = rawCall(token, http.MethodPost, "https://api.twitter.com/1.1/statuses/update.json", map[string]string{"status": strings.Join(flag.Args(), " "), "in_reply_to_status_id": inreply, "media_ids": media.String()}, &tweet)
-		if err != nil {
-			log.Fatal("cannot post tweet:", err)
-		}
-		fmt.Println("tweeted:", tweet.Identifier)
+		postText(strings.Join(flag.Args(), " "))
 	}
 }
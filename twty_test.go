@@ -0,0 +1,692 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTweetMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{
+			name: "link and mention",
+			json: `{
+				"id_str": "1",
+				"text": "Hi @golang_jp, see https://t.co/abc",
+				"user": {"name": "mattn", "screen_name": "mattn_jp"},
+				"entities": {
+					"user_mentions": [{"screen_name": "golang_jp"}],
+					"urls": [{"url": "https://t.co/abc", "expanded_url": "https://go.dev/blog", "display_url": "go.dev/blog"}]
+				}
+			}`,
+			want: "**@mattn_jp** (mattn)\n\n" +
+				"> Hi [@golang\\_jp](https://twitter.com/golang_jp), see [go.dev/blog](https://go.dev/blog)\n" +
+				"\n[](https://twitter.com/mattn_jp/status/1)\n",
+		},
+		{
+			name: "escape",
+			json: `{
+				"id_str": "2",
+				"text": "*bold* &lt;tag&gt; mail@example.com\nnext #line",
+				"user": {"screen_name": "mattn_jp"}
+			}`,
+			want: "**@mattn_jp**\n\n" +
+				"> \\*bold\\* \\<tag\\> mail@example.com\n" +
+				"> next \\#line\n" +
+				"\n[](https://twitter.com/mattn_jp/status/2)\n",
+		},
+		{
+			name: "mention not in entities",
+			json: `{
+				"id_str": "3",
+				"text": "@someone_else hi",
+				"user": {"screen_name": "mattn_jp"}
+			}`,
+			want: "**@mattn_jp**\n\n" +
+				"> @someone\\_else hi\n" +
+				"\n[](https://twitter.com/mattn_jp/status/3)\n",
+		},
+	}
+	for _, test := range tests {
+		var tweet Tweet
+		if err := json.Unmarshal([]byte(test.json), &tweet); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := tweetMarkdown(tweet); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestShowUserRawJSON(t *testing.T) {
+	body := `{"id":1,"screen_name":"mattn_jp","unknown_field":{"nested":[1,2]}}`
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+	var raw json.RawMessage
+	if err := decodeResponse(resp, &raw); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeRawJSON(&buf, raw); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["unknown_field"]; !ok {
+		t.Errorf("unknown_field is dropped: %s", buf.String())
+	}
+	var user User
+	if err := json.Unmarshal(raw, &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ScreenName != "mattn_jp" {
+		t.Errorf("got screen name %q, want %q", user.ScreenName, "mattn_jp")
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want map[string]string
+	}{
+		{
+			name: "old shape",
+			json: `{"ClientToken":"a","AccessToken":"b"}`,
+			want: map[string]string{"ClientToken": "a", "AccessToken": "b"},
+		},
+		{
+			name: "new shape",
+			json: `{"ClientToken":"a","Accounts":{"sub":{"AccessToken":"c"}},"Retries":3,"Verbose":true}`,
+			want: map[string]string{"ClientToken": "a"},
+		},
+	}
+	for _, test := range tests {
+		got, err := parseConfig([]byte(test.json))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+	if _, err := parseConfig([]byte(`["not", "object"]`)); err == nil {
+		t.Error("want error for config which is not an object")
+	}
+}
+
+func TestStoreConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "settings.json")
+	old := `{"ClientToken":"a","Accounts":{"sub":{"AccessToken":"c"}},"Retries":3}`
+	if err = ioutil.WriteFile(file, []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = storeConfig(file, map[string]string{"ClientToken": "x", "AccessToken": "y"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"ClientToken": "x",
+		"AccessToken": "y",
+		"Accounts":    map[string]interface{}{"sub": map[string]interface{}{"AccessToken": "c"}},
+		"Retries":     float64(3),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// a new file is created if it does not exist
+	file = filepath.Join(dir, "new.json")
+	if err = storeConfig(file, map[string]string{"ClientToken": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err = ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := parseConfig(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, map[string]string{"ClientToken": "a"}) {
+		t.Errorf("got %v", config)
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{
+			name:        "html error page",
+			status:      http.StatusBadGateway,
+			contentType: "text/html; charset=utf-8",
+			body:        "<html><body>502 Bad Gateway</body></html>",
+			wantErr:     `expected JSON, got text/html; charset=utf-8 (502 Bad Gateway): "<html><body>502 Bad Gateway</body></html>"`,
+		},
+		{
+			name:        "json error",
+			status:      http.StatusForbidden,
+			contentType: "application/json",
+			body:        `{"errors":[{"code":187,"message":"Status is a duplicate."}]}`,
+			wantErr:     "duplicate tweet: twitter rejects the same text as a recent tweet (code 187)",
+		},
+		{
+			name:        "empty json error",
+			status:      http.StatusServiceUnavailable,
+			contentType: "application/json",
+			body:        `{}`,
+			wantErr:     `503 Service Unavailable: "{}"`,
+		},
+		{
+			name:        "oauth2 error",
+			status:      http.StatusBadRequest,
+			contentType: "application/json",
+			body:        `{"error":"invalid_request","error_description":"Value passed for the token was invalid."}`,
+			wantErr:     "400 Bad Request: invalid_request: Value passed for the token was invalid.",
+		},
+		{
+			name:        "success",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"id_str":"1"}`,
+		},
+	}
+	for _, test := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", test.contentType)
+			w.WriteHeader(test.status)
+			io.WriteString(w, test.body)
+		}))
+		resp, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var tweet Tweet
+		err = decodeResponse(resp, &tweet)
+		resp.Body.Close()
+		ts.Close()
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			} else if tweet.Identifier != "1" {
+				t.Errorf("%s: got ID %q, want %q", test.name, tweet.Identifier, "1")
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: want error %q, got nil", test.name, test.wantErr)
+		} else if err.Error() != test.wantErr {
+			t.Errorf("%s: got error %q, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestDecodeResponseWithoutResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "json error",
+			status:  http.StatusForbidden,
+			body:    `{"errors":[{"code":139,"message":"You have already favorited this status."}]}`,
+			wantErr: "403 Forbidden: You have already favorited this status. (code 139)",
+		},
+		{
+			name:    "empty json error",
+			status:  http.StatusNotFound,
+			body:    `{}`,
+			wantErr: `404 Not Found: "{}"`,
+		},
+		{
+			name:   "success",
+			status: http.StatusOK,
+			body:   `{"id_str":"1"}`,
+		},
+	}
+	for _, test := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(test.status)
+			io.WriteString(w, test.body)
+		}))
+		resp, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		err = decodeResponse(resp, nil)
+		resp.Body.Close()
+		ts.Close()
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: want error %q, got nil", test.name, test.wantErr)
+		} else if err.Error() != test.wantErr {
+			t.Errorf("%s: got error %q, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestTrimText(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		name    string
+		content string
+		all     bool
+		want    string
+	}{
+		{name: "lf", content: "hello\n", want: "hello"},
+		{name: "crlf", content: "hello\r\n", want: "hello"},
+		{name: "no newline", content: "hello", want: "hello"},
+		{name: "inner newlines", content: "hello\n\nworld\n", want: "hello\n\nworld"},
+		{name: "only last newline", content: "  hello\n\n", want: "  hello\n"},
+		{name: "trim all", content: "  hello\r\n\r\n", all: true, want: "hello"},
+	}
+	for _, test := range tests {
+		filename := filepath.Join(dir, test.name)
+		if err := ioutil.WriteFile(filename, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		b, err := readFile(filename)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := trimText(string(b), test.all); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDedupeTweets(t *testing.T) {
+	tweets := func(ids ...string) []Tweet {
+		var result []Tweet
+		for _, id := range ids {
+			result = append(result, Tweet{Identifier: id, Text: "tweet " + id})
+		}
+		return result
+	}
+	tests := []struct {
+		name  string
+		pages [][]Tweet
+		want  []string
+	}{
+		{name: "no duplicates", pages: [][]Tweet{tweets("3", "2", "1")}, want: []string{"3", "2", "1"}},
+		{name: "overlapping pages", pages: [][]Tweet{tweets("5", "4", "3"), tweets("3", "2", "1")}, want: []string{"5", "4", "3", "2", "1"}},
+		{name: "duplicate ids", pages: [][]Tweet{tweets("2", "2", "1", "2")}, want: []string{"2", "1"}},
+		{name: "empty", pages: nil, want: nil},
+	}
+	for _, test := range tests {
+		var all []Tweet
+		for _, page := range test.pages {
+			all = append(all, page...)
+		}
+		var got []string
+		for _, tweet := range dedupeTweets(all) {
+			got = append(got, tweet.Identifier)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWriteUserResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "result.txt")
+	action := userAction{name: "follow", done: "followed"}
+	names := []string{"mattn_jp", "1234", "id:5678"}
+	errs := []error{nil, errors.New("not found"), nil}
+	if err := writeUserResults(file, action, names, errs); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readUserList(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("got %q, want %q", got, names)
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	dir := filepath.Join("home", ".config", "twty")
+	tests := []struct {
+		configFile string
+		profile    string
+		want       string
+	}{
+		{configFile: filepath.Join(dir, "settings.json"), want: filepath.Join(dir, "seen.json")},
+		{configFile: filepath.Join(dir, "settings-work.json"), profile: "work", want: filepath.Join(dir, "seen-work.json")},
+		{configFile: filepath.Join(dir, "config.json"), profile: "work", want: filepath.Join(dir, "seen-work.json")},
+	}
+	for _, test := range tests {
+		if got := stateFile(test.configFile, test.profile, "seen"); got != test.want {
+			t.Errorf("stateFile(%q, %q): got %q, want %q", test.configFile, test.profile, got, test.want)
+		}
+	}
+}
+
+func TestSeenMarkers(t *testing.T) {
+	m := &seenMarkers{ids: map[string]int64{}}
+	m.mark("home", []Tweet{{Identifier: "20"}, {Identifier: "30"}, {Identifier: "10"}})
+	m.mark("home", []Tweet{{Identifier: "25"}})
+	m.mark("replies", []Tweet{{Identifier: "5"}, {Identifier: "invalid"}})
+	if got := m.get("home"); got != 30 {
+		t.Errorf("home: got %d, want %d", got, 30)
+	}
+	if got := m.get("replies"); got != 5 {
+		t.Errorf("replies: got %d, want %d", got, 5)
+	}
+	if got, want := m.names(), []string{"home", "replies"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("names: got %q, want %q", got, want)
+	}
+	m.reset("home")
+	if got := m.get("home"); got != 0 {
+		t.Errorf("home after reset: got %d, want %d", got, 0)
+	}
+	m.reset("")
+	if got := m.names(); len(got) != 0 {
+		t.Errorf("names after reset all: got %q, want none", got)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	d := &drafts{NextID: 1}
+	first := d.add(Draft{Text: "first"})
+	second := d.add(Draft{Text: "second"})
+	if first != 1 || second != 2 {
+		t.Fatalf("got IDs %d and %d, want 1 and 2", first, second)
+	}
+	d.remove(first)
+	if third := d.add(Draft{Text: "third"}); third != 3 {
+		t.Errorf("got ID %d after remove, want %d", third, 3)
+	}
+	tests := []struct {
+		id      string
+		want    string
+		wantErr string
+	}{
+		{id: "2", want: "second"},
+		{id: "1", wantErr: "draft 1 not found"},
+		{id: "x", wantErr: `invalid draft ID: "x"`},
+	}
+	for _, test := range tests {
+		draft, err := d.find(test.id)
+		if test.wantErr != "" {
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("find(%q): got error %v, want %q", test.id, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("find(%q): unexpected error: %v", test.id, err)
+		} else if draft.Text != test.want {
+			t.Errorf("find(%q): got %q, want %q", test.id, draft.Text, test.want)
+		}
+	}
+}
+
+func TestTweetQueueFlush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// skip waits between posts
+	defer func(v bool) { dryRunWrites = v }(dryRunWrites)
+	dryRunWrites = true
+
+	q := &tweetQueue{file: filepath.Join(dir, "queue.json"), Tweets: []QueuedTweet{
+		{Text: "first"},
+		{Text: "fail", Attempts: 1},
+		{Text: "third"},
+	}}
+	posted, err := q.flush(func(queued QueuedTweet) (Tweet, error) {
+		if queued.Text == "fail" {
+			return Tweet{}, errors.New("over capacity")
+		}
+		return Tweet{Identifier: queued.Text}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posted != 2 {
+		t.Errorf("got %d posted, want %d", posted, 2)
+	}
+	want := []QueuedTweet{{Text: "fail", Attempts: 2, Error: "over capacity"}}
+	if !reflect.DeepEqual(q.Tweets, want) {
+		t.Errorf("got queue %+v, want %+v", q.Tweets, want)
+	}
+	stored, err := loadTweetQueue(q.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stored.Tweets, want) {
+		t.Errorf("got stored queue %+v, want %+v", stored.Tweets, want)
+	}
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	loc := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2024, 7, 1, 10, 30, 0, 0, loc)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "+1h30m", want: now.Add(90 * time.Minute)},
+		{in: "11:00", want: time.Date(2024, 7, 1, 11, 0, 0, 0, loc)},
+		{in: "09:00", want: time.Date(2024, 7, 2, 9, 0, 0, 0, loc)},
+		{in: "10:30", want: time.Date(2024, 7, 2, 10, 30, 0, 0, loc)},
+		{in: "2024-07-03 09:00", want: time.Date(2024, 7, 3, 9, 0, 0, 0, loc)},
+		{in: "2024-07-03T09:00:00Z", want: time.Date(2024, 7, 3, 9, 0, 0, 0, time.UTC)},
+		{in: "+tomorrow", wantErr: true},
+		{in: "someday", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseScheduleTime(test.in, loc, now)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: want error, got %v", test.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.in, err)
+		} else if !got.Equal(test.want) {
+			t.Errorf("%q: got %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	now := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	s := &schedule{Tweets: []ScheduledTweet{
+		{ID: 1, At: now.Add(-time.Hour)},
+		{ID: 2, At: now},
+		{ID: 3, At: now.Add(time.Minute)},
+		{ID: 4, At: now.Add(-time.Hour), Attempts: _MaxScheduleAttempts},
+	}}
+	var got []int
+	for _, tweet := range s.due(now) {
+		got = append(got, tweet.ID)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPostHistory(t *testing.T) {
+	h := &postHistory{}
+	for i := 0; i < _HistorySize+5; i++ {
+		h.add(fmt.Sprintf("tweet %d", i), strconv.Itoa(i))
+	}
+	if len(h.Posts) != _HistorySize {
+		t.Errorf("got %d posts, want %d", len(h.Posts), _HistorySize)
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "tweet 0", want: ""},
+		{text: "tweet 4", want: ""},
+		{text: "tweet 5", want: "5"},
+		{text: fmt.Sprintf("  tweet %d\n", _HistorySize+4), want: strconv.Itoa(_HistorySize + 4)},
+	}
+	for _, test := range tests {
+		var got string
+		if posted := h.find(test.text); posted != nil {
+			got = posted.ID
+		}
+		if got != test.want {
+			t.Errorf("find(%q): got %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestSubtractIDs(t *testing.T) {
+	tests := []struct {
+		a, b []int64
+		want []int64
+	}{
+		{a: []int64{1, 2, 3, 4}, b: []int64{2, 4}, want: []int64{1, 3}},
+		{a: []int64{3, 1, 2}, b: nil, want: []int64{3, 1, 2}},
+		{a: []int64{1, 2}, b: []int64{1, 2, 3}, want: nil},
+	}
+	for _, test := range tests {
+		if got := subtractIDs(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("subtractIDs(%v, %v): got %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestIntersectIDs(t *testing.T) {
+	tests := []struct {
+		a, b []int64
+		want []int64
+	}{
+		{a: []int64{1, 2, 3, 4}, b: []int64{4, 2}, want: []int64{2, 4}},
+		{a: []int64{1, 2, 2, 3}, b: []int64{2, 3}, want: []int64{2, 3}},
+		{a: []int64{1, 2}, b: nil, want: nil},
+	}
+	for _, test := range tests {
+		if got := intersectIDs(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("intersectIDs(%v, %v): got %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestFollowerSnapshotTake(t *testing.T) {
+	now := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	s := &followerSnapshot{}
+	followed, unfollowed := s.take([]int64{1, 2, 3}, now)
+	if followed != nil || unfollowed != nil {
+		t.Errorf("first snapshot: got %v and %v, want none", followed, unfollowed)
+	}
+	followed, unfollowed = s.take([]int64{2, 3, 4, 5}, now.Add(time.Hour))
+	if want := []int64{4, 5}; !reflect.DeepEqual(followed, want) {
+		t.Errorf("followed: got %v, want %v", followed, want)
+	}
+	if want := []int64{1}; !reflect.DeepEqual(unfollowed, want) {
+		t.Errorf("unfollowed: got %v, want %v", unfollowed, want)
+	}
+	if last := s.last(); last == nil || last.Followers != 4 || !last.Taken.Equal(now.Add(time.Hour)) {
+		t.Errorf("got last snapshot %+v", last)
+	}
+}
+
+func TestUnfollowProgressDone(t *testing.T) {
+	p := &unfollowProgress{Pending: []int64{1, 2, 3}}
+	p.done(2)
+	p.done(4)
+	if want := []int64{1, 3}; !reflect.DeepEqual(p.Pending, want) {
+		t.Errorf("got %v, want %v", p.Pending, want)
+	}
+}
+
+func TestThreadFetcherRoot(t *testing.T) {
+	tests := []struct {
+		name   string
+		tweets []Tweet
+		id     string
+		want   string
+	}{
+		{
+			name:   "thread",
+			tweets: []Tweet{{Identifier: "1"}, {Identifier: "2", InReplyToStatusID: "1"}, {Identifier: "3", InReplyToStatusID: "2"}},
+			id:     "3",
+			want:   "1",
+		},
+		{
+			name:   "loop",
+			tweets: []Tweet{{Identifier: "1", InReplyToStatusID: "2"}, {Identifier: "2", InReplyToStatusID: "1"}},
+			id:     "1",
+			want:   "2",
+		},
+	}
+	for _, test := range tests {
+		f := newThreadFetcher(nil)
+		for i := range test.tweets {
+			f.tweets[test.tweets[i].Identifier] = &test.tweets[i]
+		}
+		root, err := f.root(test.id)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		} else if root.Identifier != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, root.Identifier, test.want)
+		}
+	}
+}
@@ -0,0 +1,733 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/go-oauth/oauth"
+)
+
+const (
+	_UploadURL        = "https://upload.twitter.com/1.1/media/upload.json"
+	_MetadataURL      = "https://upload.twitter.com/1.1/media/metadata/create.json"
+	_ChunkSize        = 5 * 1024 * 1024
+	_MaxAltTextLength = 1000
+	// _MaxConcurrentUploads is the maximum number of media uploaded at once
+	_MaxConcurrentUploads = 4
+	// _MaxDownloadSize is the maximum size of media downloaded from URL,
+	// which is the limit of videos
+	_MaxDownloadSize = 512 * 1024 * 1024
+	// _ProcessingTimeout is how long to wait processing of uploaded media
+	_ProcessingTimeout = 10 * time.Minute
+	// _MaxCheckInterval is the maximum interval of polling processing
+	// status when check_after_secs is not given
+	_MaxCheckInterval = 30 * time.Second
+)
+
+// MediaUpload hold information about uploaded media
+type MediaUpload struct {
+	MediaID          int64  `json:"media_id"`
+	MediaIDString    string `json:"media_id_string"`
+	Size             int    `json:"size"`
+	ExpiresAfterSecs int    `json:"expires_after_secs"`
+	Image            struct {
+		ImageType string `json:"image_type"`
+		W         int    `json:"w"`
+		H         int    `json:"h"`
+	} `json:"image"`
+	ProcessingInfo *ProcessingInfo `json:"processing_info"`
+}
+
+// ProcessingInfo hold information about processing state of uploaded media
+type ProcessingInfo struct {
+	State           string `json:"state"`
+	CheckAfterSecs  int    `json:"check_after_secs"`
+	ProgressPercent int    `json:"progress_percent"`
+	Error           *struct {
+		Code    int    `json:"code"`
+		Name    string `json:"name"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// mediaType returns MIME type of the file guessed from its extension
+func mediaType(file string) string {
+	typ := mime.TypeByExtension(strings.ToLower(filepath.Ext(file)))
+	if i := strings.Index(typ, ";"); i >= 0 {
+		typ = typ[:i]
+	}
+	return typ
+}
+
+// sniffMediaType returns MIME type of the file sniffed from its content, or
+// guessed from its extension if unknown
+func sniffMediaType(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b := make([]byte, 512)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	typ := http.DetectContentType(b[:n])
+	if typ == "application/octet-stream" {
+		typ = mediaType(file)
+	}
+	return typ, nil
+}
+
+// mediaCategory returns category of the media file, "photo", "gif" or "video",
+// sniffed from its content. GIFs without animation are photos.
+func mediaCategory(file string) (string, error) {
+	typ, err := sniffMediaType(file)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case typ == "image/gif":
+		animated, err := isAnimatedGIF(file)
+		if err != nil {
+			return "", err
+		}
+		if !animated {
+			return "photo", nil
+		}
+		return "gif", nil
+	case strings.HasPrefix(typ, "image/"):
+		return "photo", nil
+	case strings.HasPrefix(typ, "video/"):
+		return "video", nil
+	}
+	return "", fmt.Errorf("unsupported media type %v: %v", typ, file)
+}
+
+// isAnimatedGIF returns true if the GIF has multiple frames
+func isAnimatedGIF(file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		return false, fmt.Errorf("cannot decode GIF %v: %v", file, err)
+	}
+	return len(g.Image) > 1, nil
+}
+
+// validateMedia checks the media can be attached to one tweet together
+func validateMedia(categories []string) error {
+	counts := map[string]int{}
+	for _, category := range categories {
+		counts[category]++
+	}
+	switch {
+	case counts["photo"] > 4:
+		return fmt.Errorf("too many photos: %d (up to 4 photos)", counts["photo"])
+	case counts["gif"] > 1:
+		return fmt.Errorf("too many GIFs: %d (up to 1 GIF)", counts["gif"])
+	case counts["video"] > 1:
+		return fmt.Errorf("too many videos: %d (up to 1 video)", counts["video"])
+	case counts["gif"]+counts["video"] > 0 && len(categories) > 1:
+		return fmt.Errorf("GIF or video cannot be attached with other media")
+	}
+	return nil
+}
+
+// uploadCategories hold media_category of chunked upload for categories of
+// media, which allows larger files and asynchronous processing
+var uploadCategories = map[string]string{
+	"photo": "tweet_image",
+	"gif":   "tweet_gif",
+	"video": "tweet_video",
+}
+
+// isChunkedMedia returns true if the file must be uploaded with chunked
+// upload. Videos, GIFs and files larger than a chunk are uploaded in chunks.
+func isChunkedMedia(file string, category string) bool {
+	if category == "video" || category == "gif" {
+		return true
+	}
+	fi, err := os.Stat(file)
+	return err == nil && fi.Size() > _ChunkSize
+}
+
+// uploadChunked uploads the file with INIT/APPEND/FINALIZE commands. If wait
+// is true, it waits until processing of the media is finished.
+func uploadChunked(token *oauth.Credentials, file string, category string, wait bool, res *MediaUpload) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	typ, err := sniffMediaType(file)
+	if err != nil {
+		return err
+	}
+	err = rawCall(token, http.MethodPost, _UploadURL, map[string]string{
+		"command":        "INIT",
+		"total_bytes":    strconv.FormatInt(fi.Size(), 10),
+		"media_type":     typ,
+		"media_category": uploadCategories[category],
+	}, res)
+	if err != nil {
+		return err
+	}
+	if res.MediaIDString == "" {
+		return fmt.Errorf("cannot initialize upload of %v", file)
+	}
+	mediaID := res.MediaIDString
+
+	progress := newUploadProgress(filepath.Base(file), fi.Size())
+	defer progress.done()
+	chunk := make([]byte, _ChunkSize)
+	for i := 0; ; i++ {
+		n, err := io.ReadFull(f, chunk)
+		if n > 0 {
+			if err := appendChunk(token, mediaID, i, chunk[:n], progress); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rawCall(token, http.MethodPost, _UploadURL, map[string]string{
+		"command":  "FINALIZE",
+		"media_id": mediaID,
+	}, res)
+	if err != nil {
+		return err
+	}
+	if !wait {
+		return nil
+	}
+	return waitProcessing(token, res)
+}
+
+func appendChunk(token *oauth.Credentials, mediaID string, index int, chunk []byte, progress *uploadProgress) error {
+	param := url.Values{
+		"command":       {"APPEND"},
+		"media_id":      {mediaID},
+		"segment_index": {strconv.Itoa(index)},
+	}
+	oauthClient.SignParam(token, http.MethodPost, _UploadURL, param)
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile("media", "blob")
+	if err != nil {
+		return err
+	}
+	if _, err = fw.Write(chunk); err != nil {
+		return err
+	}
+	w.Close()
+
+	req, err := http.NewRequest(http.MethodPost, _UploadURL+"?"+param.Encode(), progress.reader(&buf))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(buf.Len())
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	resp, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("cannot append chunk %d: %v: %s", index, resp.Status, b)
+	}
+	return nil
+}
+
+// showProgress shows progress bars of uploading large media, which is enabled
+// when stdout is a terminal and -json is not specified
+var showProgress bool
+
+// _ProgressMinSize is the minimum size of media to show progress bar
+const _ProgressMinSize = 1024 * 1024
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// uploadProgress draws a progress bar of uploading media on stderr
+type uploadProgress struct {
+	label string
+	total int64
+	sent  int64
+	start time.Time
+	drawn time.Time
+}
+
+// newUploadProgress returns the progress bar of the upload, or nil if it is
+// not shown. Methods of nil progress bar do nothing.
+func newUploadProgress(label string, total int64) *uploadProgress {
+	if !showProgress || total < _ProgressMinSize {
+		return nil
+	}
+	return &uploadProgress{label: label, total: total, start: time.Now()}
+}
+
+// reader returns the reader which advances the progress bar as read
+func (p *uploadProgress) reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &progressReader{r: r, progress: p}
+}
+
+func (p *uploadProgress) add(n int) {
+	p.sent += int64(n)
+	// multipart headers are counted too
+	if p.sent > p.total {
+		p.sent = p.total
+	}
+	if time.Since(p.drawn) < 100*time.Millisecond && p.sent < p.total {
+		return
+	}
+	p.drawn = time.Now()
+	const width = 30
+	filled := int(width * p.sent / p.total)
+	eta := "--"
+	if elapsed := time.Since(p.start); p.sent > 0 {
+		eta = (time.Duration(float64(elapsed) * float64(p.total-p.sent) / float64(p.sent))).Round(time.Second).String()
+	}
+	fmt.Fprintf(os.Stderr, "\r%s [%s%s] %.1f/%.1fMB %3d%% ETA %s ",
+		p.label, strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+		float64(p.sent)/(1024*1024), float64(p.total)/(1024*1024), 100*p.sent/p.total, eta)
+}
+
+func (p *uploadProgress) done() {
+	if p != nil && !p.drawn.IsZero() {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress *uploadProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.progress.add(n)
+	return n, err
+}
+
+// waitProcessing polls the status of the media until processing succeeds, so
+// that the media ID is not attached to a tweet before it is ready. It waits
+// check_after_secs between polls, or backs off exponentially if it is not
+// given, and gives up after _ProcessingTimeout.
+func waitProcessing(token *oauth.Credentials, res *MediaUpload) error {
+	deadline := time.Now().Add(_ProcessingTimeout)
+	backoff := time.Second
+	for res.ProcessingInfo != nil {
+		info := res.ProcessingInfo
+		switch info.State {
+		case "succeeded":
+			return nil
+		case "failed":
+			if info.Error != nil {
+				return fmt.Errorf("processing failed: %v", info.Error.Message)
+			}
+			return fmt.Errorf("processing failed")
+		}
+		wait := time.Duration(info.CheckAfterSecs) * time.Second
+		if wait <= 0 {
+			wait = backoff
+			if backoff *= 2; backoff > _MaxCheckInterval {
+				backoff = _MaxCheckInterval
+			}
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("processing of media %v is not finished in %v", res.MediaIDString, _ProcessingTimeout)
+		}
+		fmt.Fprintf(os.Stderr, "processing media %v: %s %d%%\n", res.MediaIDString, info.State, info.ProgressPercent)
+		time.Sleep(wait)
+		res.ProcessingInfo = nil
+		err := rawCall(token, http.MethodGet, _UploadURL, map[string]string{
+			"command":  "STATUS",
+			"media_id": res.MediaIDString,
+		}, res)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadMedia uploads the files and returns their media IDs. Files uploaded
+// recently are not uploaded again with the cache stored in cacheFile.
+func uploadMedia(token *oauth.Credentials, files []string, cacheFile string, wait bool) ([]string, error) {
+	files, cleanup, err := downloadMediaURLs(files)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+	files, cleanupImages, err := preprocessImages(files)
+	defer cleanupImages()
+	if err != nil {
+		return nil, err
+	}
+	if dryRunWrites {
+		return dryRunMedia(files)
+	}
+	cache, err := loadMediaCache(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(files))
+	categories := make([]string, len(files))
+	keys := make([]string, len(files))
+	var uploads []int
+	for i, file := range files {
+		if keys[i], err = mediaKey(file); err != nil {
+			return nil, err
+		}
+		if entry, ok := cache.get(keys[i]); ok && entry.Category != "" {
+			ids[i] = entry.MediaID
+			categories[i] = entry.Category
+			continue
+		}
+		if categories[i], err = mediaCategory(file); err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, i)
+	}
+	if err = validateMedia(categories); err != nil {
+		return nil, err
+	}
+
+	// progress bars of concurrent uploads would be mixed up
+	if len(uploads) > 1 {
+		defer func(show bool) { showProgress = show }(showProgress)
+		showProgress = false
+	}
+	entries := make([]mediaCacheEntry, len(files))
+	errs := make([]error, len(files))
+	sem := make(chan struct{}, _MaxConcurrentUploads)
+	var wg sync.WaitGroup
+	for _, i := range uploads {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			entries[i], errs[i] = uploadFile(token, files[i], categories[i], wait)
+		}(i)
+	}
+	wg.Wait()
+
+	// uploaded media are cached even if others failed, not to upload them
+	// again when retrying
+	for _, i := range uploads {
+		if errs[i] == nil {
+			cache.put(keys[i], entries[i])
+			ids[i] = entries[i].MediaID
+		}
+	}
+	if err = cache.save(); err != nil {
+		return nil, err
+	}
+	for _, i := range uploads {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+	}
+	return ids, nil
+}
+
+// uploadFile uploads the media file, and returns the cache entry of it
+func uploadFile(token *oauth.Credentials, file string, category string, wait bool) (mediaCacheEntry, error) {
+	var res MediaUpload
+	var err error
+	if isChunkedMedia(file, category) {
+		err = uploadChunked(token, file, category, wait, &res)
+	} else {
+		err = upload(token, file, map[string]string{"media_category": uploadCategories[category]}, &res)
+	}
+	if err != nil {
+		return mediaCacheEntry{}, err
+	}
+	if res.MediaIDString == "" {
+		return mediaCacheEntry{}, fmt.Errorf("no media ID returned for %v", file)
+	}
+	expires := 24 * time.Hour
+	if res.ExpiresAfterSecs > 0 {
+		expires = time.Duration(res.ExpiresAfterSecs) * time.Second
+	}
+	return mediaCacheEntry{
+		MediaID:  res.MediaIDString,
+		Category: category,
+		Expires:  time.Now().Add(expires),
+	}, nil
+}
+
+// validateAltTexts checks alt texts given with -alt can be matched to media
+func validateAltTexts(alts []string, files []string) error {
+	if len(alts) > len(files) {
+		return fmt.Errorf("%d alt texts for %d media", len(alts), len(files))
+	}
+	for i, alt := range alts {
+		if n := len([]rune(alt)); n > _MaxAltTextLength {
+			return fmt.Errorf("alt text of %v is too long: %d/%d", files[i], n, _MaxAltTextLength)
+		}
+	}
+	return nil
+}
+
+// setAltTexts sets alt texts to the uploaded media of the IDs in order. Empty
+// alt texts are skipped.
+func setAltTexts(token *oauth.Credentials, ids []string, alts []string) error {
+	for i, alt := range alts {
+		if alt == "" {
+			continue
+		}
+		if dryRunWrites {
+			fmt.Printf("alt text of %s: %s\n", ids[i], alt)
+			continue
+		}
+		if err := setAltText(token, ids[i], alt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setAltText(token *oauth.Credentials, mediaID string, alt string) error {
+	body := map[string]interface{}{
+		"media_id": mediaID,
+		"alt_text": map[string]string{"text": alt},
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, _MetadataURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err = oauthClient.SetAuthorizationHeader(req.Header, token, http.MethodPost, req.URL, nil); err != nil {
+		return err
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("cannot set alt text of media %v: %v: %s", mediaID, resp.Status, b)
+	}
+	return nil
+}
+
+// isMediaURL returns true if the media of -m is URL to download
+func isMediaURL(file string) bool {
+	return strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://")
+}
+
+// mediaPath returns absolute path of the media file to store, or URL as is
+func mediaPath(file string) (string, error) {
+	if isMediaURL(file) {
+		return file, nil
+	}
+	return filepath.Abs(file)
+}
+
+// downloadMediaURLs downloads media of URLs into temporary files, and returns
+// paths of the files replacing the URLs. cleanup removes the temporary files.
+func downloadMediaURLs(files []string) ([]string, func(), error) {
+	var temps []string
+	cleanup := func() {
+		for _, temp := range temps {
+			os.Remove(temp)
+		}
+	}
+	local := make([]string, len(files))
+	for i, file := range files {
+		if !isMediaURL(file) {
+			local[i] = file
+			continue
+		}
+		temp, err := downloadMedia(file)
+		if temp != "" {
+			temps = append(temps, temp)
+		}
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("cannot download %v: %v", file, err)
+		}
+		local[i] = temp
+	}
+	return local, cleanup, nil
+}
+
+// downloadMedia downloads the image or video of the URL into a temporary
+// file, and returns the path of the file
+func downloadMedia(u string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("%v", resp.Status)
+	}
+	body := bufio.NewReader(resp.Body)
+	typ, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if typ == "" || typ == "application/octet-stream" {
+		b, _ := body.Peek(512)
+		typ = http.DetectContentType(b)
+	}
+	if !strings.HasPrefix(typ, "image/") && !strings.HasPrefix(typ, "video/") {
+		return "", fmt.Errorf("not an image or a video: %q", typ)
+	}
+	if resp.ContentLength > _MaxDownloadSize {
+		return "", fmt.Errorf("too large: %d bytes", resp.ContentLength)
+	}
+	// the extension is used to guess type of the media
+	ext := path.Ext(req.URL.Path)
+	if exts, _ := mime.ExtensionsByType(typ); mediaType("x"+ext) != typ && len(exts) > 0 {
+		ext = exts[0]
+	}
+	f, err := ioutil.TempFile("", "twty-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(body, _MaxDownloadSize+1))
+	if err != nil {
+		return f.Name(), err
+	}
+	if n > _MaxDownloadSize {
+		return f.Name(), fmt.Errorf("too large: more than %d bytes", _MaxDownloadSize)
+	}
+	return f.Name(), nil
+}
+
+// dryRunMedia shows the files to upload without uploading them for -dry-run,
+// and returns placeholders of media IDs like "<cat.jpg>".
+func dryRunMedia(files []string) ([]string, error) {
+	ids := make([]string, len(files))
+	categories := make([]string, len(files))
+	for i, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if categories[i], err = mediaCategory(file); err != nil {
+			return nil, err
+		}
+		fmt.Printf("upload %s (%s, %d bytes)\n", file, categories[i], fi.Size())
+		ids[i] = "<" + filepath.Base(file) + ">"
+	}
+	return ids, validateMedia(categories)
+}
+
+// mediaCacheEntry hold media ID of uploaded file and when it expires
+type mediaCacheEntry struct {
+	MediaID  string    `json:"media_id"`
+	Category string    `json:"category"`
+	Expires  time.Time `json:"expires"`
+}
+
+// mediaCache hold media IDs of recently uploaded files, so that retrying to
+// post does not upload the same file again.
+type mediaCache struct {
+	file    string
+	entries map[string]mediaCacheEntry
+}
+
+// loadMediaCache loads the cache from the file, dropping expired entries
+func loadMediaCache(file string) (*mediaCache, error) {
+	c := &mediaCache{file: file, entries: map[string]mediaCacheEntry{}}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &c.entries); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %v: %v", file, err)
+	}
+	now := time.Now()
+	for k, v := range c.entries {
+		if now.After(v.Expires) {
+			delete(c.entries, k)
+		}
+	}
+	return c, nil
+}
+
+func (c *mediaCache) get(key string) (mediaCacheEntry, bool) {
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *mediaCache) put(key string, entry mediaCacheEntry) {
+	c.entries[key] = entry
+}
+
+func (c *mediaCache) save() error {
+	b, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.file, b, 0600)
+}
+
+// mediaKey returns key of the file for mediaCache from its content and size
+func mediaKey(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)) + "-" + strconv.FormatInt(n, 10), nil
+}
@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/go-oauth/oauth"
+)
+
+const (
+	_APIv2Base     = "https://api.twitter.com/2/"
+	_TweetFieldsV2 = "created_at,author_id,public_metrics,source,attachments,referenced_tweets,lang"
+	_UserFieldsV2  = "name,username,description,profile_image_url,public_metrics,verified,protected,location,url,entities,created_at"
+	_MediaFieldsV2 = "media_key,type,url,preview_image_url,alt_text"
+)
+
+const (
+	_PollMinOptions      = 2
+	_PollMaxOptions      = 4
+	_PollMaxOptionLength = 25
+	// durations of polls in minutes, from 5 minutes to 7 days
+	_PollMinDuration = 5
+	_PollMaxDuration = 7 * 24 * 60
+)
+
+// TweetV2 hold information about tweet returned from API v2
+type TweetV2 struct {
+	ID            string `json:"id"`
+	Text          string `json:"text"`
+	AuthorID      string `json:"author_id"`
+	CreatedAt     string `json:"created_at"`
+	Source        string `json:"source"`
+	Lang          string `json:"lang"`
+	PublicMetrics struct {
+		RetweetCount int `json:"retweet_count"`
+		ReplyCount   int `json:"reply_count"`
+		LikeCount    int `json:"like_count"`
+		QuoteCount   int `json:"quote_count"`
+	} `json:"public_metrics"`
+	Attachments struct {
+		MediaKeys []string `json:"media_keys"`
+	} `json:"attachments"`
+	ReferencedTweets []struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"referenced_tweets"`
+}
+
+// UserV2 hold information about user returned from API v2
+type UserV2 struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Username        string `json:"username"`
+	Description     string `json:"description"`
+	ProfileImageURL string `json:"profile_image_url"`
+	PublicMetrics   struct {
+		FollowersCount int `json:"followers_count"`
+		FollowingCount int `json:"following_count"`
+		TweetCount     int `json:"tweet_count"`
+	} `json:"public_metrics"`
+	Verified  bool   `json:"verified"`
+	Protected bool   `json:"protected"`
+	Location  string `json:"location"`
+	URL       string `json:"url"`
+	CreatedAt string `json:"created_at"`
+	Entities  struct {
+		URL struct {
+			URLs []struct {
+				URL         string `json:"url"`
+				ExpandedURL string `json:"expanded_url"`
+			} `json:"urls"`
+		} `json:"url"`
+	} `json:"entities"`
+}
+
+// User converts the user of API v2 to User
+func (u UserV2) User() User {
+	id, _ := strconv.Atoi(u.ID)
+	user := User{
+		Id:              id,
+		Name:            u.Name,
+		ScreenName:      u.Username,
+		FollowersCount:  u.PublicMetrics.FollowersCount,
+		FriendsCount:    u.PublicMetrics.FollowingCount,
+		ProfileImageURL: u.ProfileImageURL,
+		Description:     u.Description,
+		Verified:        u.Verified,
+		Protected:       u.Protected,
+		Location:        u.Location,
+		URL:             u.URL,
+		StatusesCount:   u.PublicMetrics.TweetCount,
+		CreatedAt:       u.CreatedAt,
+		Entities:        u.Entities,
+	}
+	if t, err := time.Parse(time.RFC3339, u.CreatedAt); err == nil {
+		user.CreatedAt = t.Format(_TimeLayout)
+	}
+	return user
+}
+
+// MediaV2 hold information about media returned from API v2
+type MediaV2 struct {
+	MediaKey        string `json:"media_key"`
+	Type            string `json:"type"`
+	URL             string `json:"url"`
+	PreviewImageURL string `json:"preview_image_url"`
+	AltText         string `json:"alt_text"`
+}
+
+// ErrorV2 hold information about error returned from API v2
+type ErrorV2 struct {
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+	Type   string `json:"type"`
+}
+
+// MetaV2 hold information about pagination of API v2
+type MetaV2 struct {
+	ResultCount int    `json:"result_count"`
+	NewestID    string `json:"newest_id"`
+	OldestID    string `json:"oldest_id"`
+	NextToken   string `json:"next_token"`
+}
+
+// IncludesV2 hold information about users, media and tweets referred from
+// tweets of API v2
+type IncludesV2 struct {
+	Users  []UserV2  `json:"users"`
+	Media  []MediaV2 `json:"media"`
+	Tweets []TweetV2 `json:"tweets"`
+}
+
+// ResponseV2 hold information about tweets response of API v2
+type ResponseV2 struct {
+	Data     []TweetV2  `json:"data"`
+	Includes IncludesV2 `json:"includes"`
+	Meta     MetaV2     `json:"meta"`
+	Errors   []ErrorV2  `json:"errors"`
+}
+
+// Tweets converts the response of API v2 to tweets
+func (r *ResponseV2) Tweets() []Tweet {
+	users := make(map[string]UserV2)
+	for _, user := range r.Includes.Users {
+		users[user.ID] = user
+	}
+	media := make(map[string]MediaV2)
+	for _, m := range r.Includes.Media {
+		media[m.MediaKey] = m
+	}
+	referenced := make(map[string]TweetV2)
+	for _, data := range r.Includes.Tweets {
+		referenced[data.ID] = data
+	}
+	tweets := make([]Tweet, len(r.Data))
+	for i, data := range r.Data {
+		tweets[i] = data.tweet(users, media)
+		for _, ref := range data.ReferencedTweets {
+			t, ok := referenced[ref.ID]
+			if !ok {
+				continue
+			}
+			tweet := t.tweet(users, media)
+			switch ref.Type {
+			case "quoted":
+				tweets[i].QuotedStatus = &tweet
+			case "retweeted":
+				tweets[i].RetweetedStatus = &tweet
+				tweets[i].Text = "RT @" + tweet.User.ScreenName + ": " + tweet.Text
+			}
+		}
+	}
+	return tweets
+}
+
+// tweet converts the tweet of API v2 to Tweet with expanded users and media
+func (data TweetV2) tweet(users map[string]UserV2, media map[string]MediaV2) Tweet {
+	var tweet Tweet
+	tweet.Identifier = data.ID
+	tweet.Text = data.Text
+	tweet.Source = data.Source
+	tweet.Lang = data.Lang
+	tweet.CreatedAt = data.CreatedAt
+	if t, err := time.Parse(time.RFC3339, data.CreatedAt); err == nil {
+		tweet.CreatedAt = t.Format(_TimeLayout)
+	}
+	replyCount := data.PublicMetrics.ReplyCount
+	quoteCount := data.PublicMetrics.QuoteCount
+	tweet.ReplyCount = &replyCount
+	tweet.QuoteCount = &quoteCount
+	tweet.RetweetCount = data.PublicMetrics.RetweetCount
+	tweet.FavoriteCount = data.PublicMetrics.LikeCount
+	if user, ok := users[data.AuthorID]; ok {
+		tweet.User.Name = user.Name
+		tweet.User.ScreenName = user.Username
+		tweet.User.FollowersCount = user.PublicMetrics.FollowersCount
+		tweet.User.ProfileImageURL = user.ProfileImageURL
+	}
+	for _, ref := range data.ReferencedTweets {
+		if ref.Type == "replied_to" {
+			tweet.InReplyToStatusID = ref.ID
+		}
+	}
+	for _, key := range data.Attachments.MediaKeys {
+		m, ok := media[key]
+		if !ok {
+			continue
+		}
+		u := m.URL
+		if u == "" {
+			u = m.PreviewImageURL
+		}
+		tweet.ExtendedEntities.Media = append(tweet.ExtendedEntities.Media, Media{
+			Identifier:    m.MediaKey,
+			Type:          m.Type,
+			MediaURLHTTPS: u,
+			ExtAltText:    m.AltText,
+		})
+	}
+	return tweet
+}
+
+// err returns error of the response when no data is returned
+func (r *ResponseV2) err() error {
+	if len(r.Data) > 0 || len(r.Errors) == 0 {
+		return nil
+	}
+	return errorV2(r.Errors)
+}
+
+func errorV2(errs []ErrorV2) error {
+	var msgs []string
+	for _, e := range errs {
+		if e.Detail != "" {
+			msgs = append(msgs, e.Detail)
+		} else {
+			msgs = append(msgs, e.Title)
+		}
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "; "))
+}
+
+// jsonCall calls API v2, which takes parameters in the query and body as JSON
+func jsonCall(token *oauth.Credentials, method string, uri string, opt map[string]string, body interface{}, res interface{}) error {
+	return jsonRequest(method, uri, opt, body, res, func(req *http.Request) error {
+		return oauthClient.SetAuthorizationHeader(req.Header, token, method, req.URL, nil)
+	})
+}
+
+// bearerJSONCall calls API v2 with access token of OAuth 2.0
+func bearerJSONCall(bearer string, method string, uri string, opt map[string]string, body interface{}, res interface{}) error {
+	return jsonRequest(method, uri, opt, body, res, func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		return nil
+	})
+}
+
+func jsonRequest(method string, uri string, opt map[string]string, body interface{}, res interface{}, authorize func(*http.Request) error) error {
+	param := make(url.Values)
+	for k, v := range opt {
+		param.Set(k, v)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if len(param) > 0 {
+		u.RawQuery = param.Encode()
+	}
+	if err = guardWrite(method, uri, param, body); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if body != nil {
+		if err = json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, u.String(), &buf)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err = authorize(req); err != nil {
+		return err
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, res)
+}
+
+// optV2 converts parameters of API v1.1 to API v2, and adds fields to expand
+// authors and media of tweets.
+func optV2(opt map[string]string, min int) map[string]string {
+	res := map[string]string{
+		"tweet.fields": _TweetFieldsV2,
+		"user.fields":  _UserFieldsV2,
+		"media.fields": _MediaFieldsV2,
+		"expansions":   "author_id,attachments.media_keys,referenced_tweets.id,referenced_tweets.id.author_id",
+	}
+	var exclude []string
+	for k, v := range opt {
+		switch k {
+		case "count":
+			// max_results must be in the range from min to 100
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				continue
+			}
+			if n < min {
+				n = min
+			} else if n > 100 {
+				n = 100
+			}
+			res["max_results"] = strconv.Itoa(n)
+		case "since_id":
+			res["since_id"] = v
+		case "max_id":
+			// until_id is exclusive while max_id is inclusive
+			if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+				res["until_id"] = strconv.FormatInt(id+1, 10)
+			}
+		case "q":
+			res["query"] = v
+		case "start_time", "end_time":
+			res[k] = v
+		case "exclude_replies":
+			if v == "true" {
+				exclude = append(exclude, "replies")
+			}
+		case "include_rts":
+			if v == "false" {
+				exclude = append(exclude, "retweets")
+			}
+		}
+	}
+	if len(exclude) > 0 {
+		sort.Strings(exclude)
+		res["exclude"] = strings.Join(exclude, ",")
+	}
+	return res
+}
+
+// timelineV2 fetches tweets of the timeline of API v2
+func timelineV2(token *oauth.Credentials, uri string, opt map[string]string, min int) ([]Tweet, error) {
+	var res ResponseV2
+	err := jsonCall(token, http.MethodGet, uri, optV2(opt, min), nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	if err = res.err(); err != nil {
+		return nil, err
+	}
+	return res.Tweets(), nil
+}
+
+// homeTimelineV2 fetches home timeline of the user
+func homeTimelineV2(token *oauth.Credentials, userID string, opt map[string]string) ([]Tweet, error) {
+	return timelineV2(token, _APIv2Base+"users/"+userID+"/timelines/reverse_chronological", opt, 1)
+}
+
+// mentionsV2 fetches tweets mentioning the user
+func mentionsV2(token *oauth.Credentials, userID string, opt map[string]string) ([]Tweet, error) {
+	return timelineV2(token, _APIv2Base+"users/"+userID+"/mentions", opt, 5)
+}
+
+// userTweetsV2 fetches tweets of the user
+func userTweetsV2(token *oauth.Credentials, userID string, opt map[string]string) ([]Tweet, error) {
+	return timelineV2(token, _APIv2Base+"users/"+userID+"/tweets", opt, 5)
+}
+
+// quoteTweetsV2 fetches tweets quoting the tweet
+func quoteTweetsV2(token *oauth.Credentials, id string, opt map[string]string) ([]Tweet, error) {
+	opt = optV2(opt, 10)
+	delete(opt, "since_id")
+	delete(opt, "until_id")
+	var res ResponseV2
+	err := jsonCall(token, http.MethodGet, _APIv2Base+"tweets/"+id+"/quote_tweets", opt, nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	if err = res.err(); err != nil {
+		return nil, err
+	}
+	return res.Tweets(), nil
+}
+
+// likedTweetsV2 fetches tweets liked by the user. since_id and until_id are
+// not supported by the endpoint.
+func likedTweetsV2(token *oauth.Credentials, userID string, opt map[string]string) ([]Tweet, error) {
+	opt = optV2(opt, 10)
+	delete(opt, "since_id")
+	delete(opt, "until_id")
+	var res ResponseV2
+	err := jsonCall(token, http.MethodGet, _APIv2Base+"users/"+userID+"/liked_tweets", opt, nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	if err = res.err(); err != nil {
+		return nil, err
+	}
+	return res.Tweets(), nil
+}
+
+// searchV2 searches recent tweets
+func searchV2(token *oauth.Credentials, opt map[string]string) ([]Tweet, error) {
+	return timelineV2(token, _APIv2Base+"tweets/search/recent", opt, 10)
+}
+
+// lookupTweetV2 fetches the tweet of the ID
+func lookupTweetV2(token *oauth.Credentials, id string) (Tweet, error) {
+	var res struct {
+		Data     *TweetV2   `json:"data"`
+		Includes IncludesV2 `json:"includes"`
+		Errors   []ErrorV2  `json:"errors"`
+	}
+	err := jsonCall(token, http.MethodGet, _APIv2Base+"tweets/"+id, optV2(nil, 0), nil, &res)
+	if err != nil {
+		return Tweet{}, err
+	}
+	if res.Data == nil {
+		if len(res.Errors) > 0 {
+			return Tweet{}, errorV2(res.Errors)
+		}
+		return Tweet{}, fmt.Errorf("tweet %v not found", id)
+	}
+	r := ResponseV2{Data: []TweetV2{*res.Data}, Includes: res.Includes}
+	return r.Tweets()[0], nil
+}
+
+// userV2 fetches the user by screen name, or ID prefixed with "id:", or the
+// authorized user if the screen name is empty.
+func userV2(token *oauth.Credentials, screenName string) (UserV2, error) {
+	uri := _APIv2Base + "users/me"
+	if strings.HasPrefix(screenName, "id:") {
+		uri = _APIv2Base + "users/" + url.PathEscape(strings.TrimPrefix(screenName, "id:"))
+	} else if screenName != "" {
+		uri = _APIv2Base + "users/by/username/" + url.PathEscape(screenName)
+	}
+	var res struct {
+		Data   *UserV2   `json:"data"`
+		Errors []ErrorV2 `json:"errors"`
+	}
+	err := jsonCall(token, http.MethodGet, uri, map[string]string{"user.fields": _UserFieldsV2}, nil, &res)
+	if err != nil {
+		return UserV2{}, err
+	}
+	if res.Data == nil {
+		if len(res.Errors) > 0 {
+			return UserV2{}, errorV2(res.Errors)
+		}
+		return UserV2{}, fmt.Errorf("cannot get user: %s", screenName)
+	}
+	return *res.Data, nil
+}
+
+// postTweetV2 posts the tweet with parameters of statuses/update of API v1.1
+func postTweetV2(token *oauth.Credentials, opt map[string]string, res *Tweet) error {
+	body := map[string]interface{}{"text": opt["status"]}
+	if id := opt["in_reply_to_status_id"]; id != "" {
+		body["reply"] = map[string]string{"in_reply_to_tweet_id": id}
+	}
+	if ids := opt["media_ids"]; ids != "" {
+		body["media"] = map[string][]string{"media_ids": strings.Split(ids, ",")}
+	}
+	if u := opt["attachment_url"]; u != "" {
+		body["quote_tweet_id"] = path.Base(u)
+	}
+	if id := opt["place_id"]; id != "" {
+		body["geo"] = map[string]string{"place_id": id}
+	}
+	if settings := opt["reply_settings"]; settings != "" {
+		body["reply_settings"] = settings
+	}
+	if options := opt["poll_options"]; options != "" {
+		duration, err := strconv.Atoi(opt["poll_duration_minutes"])
+		if err != nil {
+			return fmt.Errorf("invalid duration of poll: %v", err)
+		}
+		body["poll"] = map[string]interface{}{
+			"options":          strings.Split(options, ","),
+			"duration_minutes": duration,
+		}
+	}
+	var data struct {
+		Data struct {
+			ID   string `json:"id"`
+			Text string `json:"text"`
+		} `json:"data"`
+		Errors []ErrorV2 `json:"errors"`
+	}
+	err := jsonCall(token, http.MethodPost, _APIv2Base+"tweets", nil, body, &data)
+	if err != nil {
+		return err
+	}
+	if data.Data.ID == "" && len(data.Errors) > 0 {
+		return errorV2(data.Errors)
+	}
+	res.Identifier = data.Data.ID
+	res.Text = data.Data.Text
+	return nil
+}
+
+// replySettingsV2 hold reply_settings of API v2 for -reply-to. Everyone can
+// reply to tweets without reply_settings.
+var replySettingsV2 = map[string]string{
+	"everyone":  "",
+	"following": "following",
+	"mentioned": "mentionedUsers",
+}
+
+// parsePoll splits comma separated options of a poll, and validates them and
+// the duration in minutes
+func parsePoll(s string, duration int) ([]string, error) {
+	var options []string
+	for _, option := range strings.Split(s, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return nil, fmt.Errorf("empty option of poll: %q", s)
+		}
+		if n := len([]rune(option)); n > _PollMaxOptionLength {
+			return nil, fmt.Errorf("option of poll is too long: %q (%d/%d)", option, n, _PollMaxOptionLength)
+		}
+		options = append(options, option)
+	}
+	if len(options) < _PollMinOptions || len(options) > _PollMaxOptions {
+		return nil, fmt.Errorf("poll needs %d to %d options: %d given", _PollMinOptions, _PollMaxOptions, len(options))
+	}
+	if duration < _PollMinDuration || duration > _PollMaxDuration {
+		return nil, fmt.Errorf("duration of poll must be %d to %d minutes: %d given", _PollMinDuration, _PollMaxDuration, duration)
+	}
+	return options, nil
+}
+
+// deleteTweetV2 deletes the tweet. As API v2 does not return the deleted
+// tweet, it is fetched before deleting.
+func deleteTweetV2(token *oauth.Credentials, id string, res *Tweet) error {
+	tweet, err := lookupTweetV2(token, id)
+	if err != nil {
+		return err
+	}
+	var data struct {
+		Data struct {
+			Deleted bool `json:"deleted"`
+		} `json:"data"`
+		Errors []ErrorV2 `json:"errors"`
+	}
+	err = jsonCall(token, http.MethodDelete, _APIv2Base+"tweets/"+id, nil, nil, &data)
+	if err != nil {
+		return err
+	}
+	if !data.Data.Deleted {
+		if len(data.Errors) > 0 {
+			return errorV2(data.Errors)
+		}
+		return fmt.Errorf("tweet %v is not deleted", id)
+	}
+	*res = tweet
+	return nil
+}
+
+// likeV2 likes the tweet as the user
+func likeV2(token *oauth.Credentials, userID string, id string) error {
+	return actionV2(token, _APIv2Base+"users/"+userID+"/likes", id)
+}
+
+// unlikeV2 undoes like of the tweet as the user
+func unlikeV2(token *oauth.Credentials, userID string, id string) error {
+	var res struct {
+		Data   map[string]bool `json:"data"`
+		Errors []ErrorV2       `json:"errors"`
+	}
+	err := jsonCall(token, http.MethodDelete, _APIv2Base+"users/"+userID+"/likes/"+id, nil, nil, &res)
+	if err != nil {
+		return err
+	}
+	if res.Data == nil && len(res.Errors) > 0 {
+		return errorV2(res.Errors)
+	}
+	return nil
+}
+
+// retweetV2 retweets the tweet as the user
+func retweetV2(token *oauth.Credentials, userID string, id string) error {
+	return actionV2(token, _APIv2Base+"users/"+userID+"/retweets", id)
+}
+
+func actionV2(token *oauth.Credentials, uri string, id string) error {
+	var res struct {
+		Data   map[string]bool `json:"data"`
+		Errors []ErrorV2       `json:"errors"`
+	}
+	err := jsonCall(token, http.MethodPost, uri, nil, map[string]string{"tweet_id": id}, &res)
+	if err != nil {
+		return err
+	}
+	if res.Data == nil && len(res.Errors) > 0 {
+		return errorV2(res.Errors)
+	}
+	return nil
+}
+
+// bookmarksV2 fetches bookmarks of the user following pagination_token until
+// count tweets are fetched
+func bookmarksV2(bearer string, userID string, count int) ([]Tweet, error) {
+	var tweets []Tweet
+	opt := optV2(map[string]string{"count": strconv.Itoa(count)}, 1)
+	for len(tweets) < count {
+		var res ResponseV2
+		err := bearerJSONCall(bearer, http.MethodGet, _APIv2Base+"users/"+userID+"/bookmarks", opt, nil, &res)
+		if err != nil {
+			return nil, err
+		}
+		if err = res.err(); err != nil {
+			return nil, err
+		}
+		tweets = append(tweets, res.Tweets()...)
+		if res.Meta.NextToken == "" {
+			break
+		}
+		opt["pagination_token"] = res.Meta.NextToken
+	}
+	if len(tweets) > count {
+		tweets = tweets[:count]
+	}
+	return tweets, nil
+}
+
+// bookmarkV2 adds the tweet to bookmarks of the user, or removes it if remove
+// is true
+func bookmarkV2(bearer string, userID string, id string, remove bool) error {
+	var res struct {
+		Data   map[string]bool `json:"data"`
+		Errors []ErrorV2       `json:"errors"`
+	}
+	var err error
+	if remove {
+		err = bearerJSONCall(bearer, http.MethodDelete, _APIv2Base+"users/"+userID+"/bookmarks/"+id, nil, nil, &res)
+	} else {
+		err = bearerJSONCall(bearer, http.MethodPost, _APIv2Base+"users/"+userID+"/bookmarks", nil, map[string]string{"tweet_id": id}, &res)
+	}
+	if err != nil {
+		return err
+	}
+	if res.Data == nil && len(res.Errors) > 0 {
+		return errorV2(res.Errors)
+	}
+	return nil
+}
+
+// bearerUserIDV2 returns ID of the user authorized with OAuth 2.0
+func bearerUserIDV2(bearer string) (string, error) {
+	var res struct {
+		Data   *UserV2   `json:"data"`
+		Errors []ErrorV2 `json:"errors"`
+	}
+	err := bearerJSONCall(bearer, http.MethodGet, _APIv2Base+"users/me", nil, nil, &res)
+	if err != nil {
+		return "", err
+	}
+	if res.Data == nil {
+		if len(res.Errors) > 0 {
+			return "", errorV2(res.Errors)
+		}
+		return "", fmt.Errorf("cannot get user")
+	}
+	return res.Data.ID, nil
+}